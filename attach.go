@@ -0,0 +1,118 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// Attachment describes an uploaded file referenced by an [attach] tag.
+type Attachment struct {
+	URL      string
+	MIMEType string
+	Filename string
+	// Width and Height are the size of images and videos, if known.
+	Width  int
+	Height int
+	// ThumbnailURL, if set, is shown for images instead of the full image,
+	// unless the tag has type=full.
+	ThumbnailURL string
+	// Forbidden is set when the viewer may not see the attachment.
+	Forbidden bool
+}
+
+// AttachmentResolver looks up attachments by ID for the viewer described by
+// ctx, which is the compiler's Context. Attachments that don't resolve are
+// shown as missing.
+type AttachmentResolver interface {
+	Resolve(ctx context.Context, id string) (Attachment, bool)
+}
+
+// AttachmentResolverFunc is an adapter to allow the use of ordinary
+// functions as attachment resolvers.
+type AttachmentResolverFunc func(ctx context.Context, id string) (Attachment, bool)
+
+func (f AttachmentResolverFunc) Resolve(ctx context.Context, id string) (Attachment, bool) {
+	return f(ctx, id)
+}
+
+// compileAttachment compiles an [attach] tag, such as [attach]123[/attach]
+// or [attach=123 type=full]. Tags are left as written when the compiler
+// has no Attachments resolver.
+func compileAttachment(node *BBCodeNode) (*HTMLTag, bool) {
+	if node.Compiler == nil || node.Compiler.Attachments == nil {
+		return DefaultTagCompiler(node)
+	}
+	c := node.Compiler
+	in := node.GetOpeningTag()
+	id := strings.TrimSpace(in.Value)
+	if id == "" {
+		id = strings.TrimSpace(CompileText(node))
+	}
+	attachment, ok := c.Attachments.Resolve(c.Context(), id)
+	switch {
+	case !ok || attachment.URL == "":
+		return attachmentPlaceholder("missing", c.Message("attachment.missing")), false
+	case attachment.Forbidden:
+		return attachmentPlaceholder("forbidden", c.Message("attachment.forbidden")), false
+	}
+
+	src := ValidURL(attachment.URL)
+	link := NewHTMLTag("")
+	link.Name = "a"
+	link.Attrs["href"] = src
+	filename := attachment.Filename
+	if filename == "" {
+		filename = src
+	}
+	link.AppendChild(NewHTMLTag(filename))
+
+	out := NewHTMLTag("")
+	switch mediaType := strings.ToLower(attachment.MIMEType); {
+	case strings.HasPrefix(mediaType, "image/"):
+		out.Name = "img"
+		out.Attrs["src"] = src
+		if attachment.ThumbnailURL != "" && in.Args["type"] != "full" {
+			out.Attrs["src"] = ValidURL(attachment.ThumbnailURL)
+		} else {
+			setDimensions(out, attachment)
+		}
+		out.Attrs["alt"] = attachment.Filename
+		out.Attrs["loading"] = "lazy"
+		if out.Attrs["src"] != src {
+			link.Children = nil
+			return link.AppendChild(out), false
+		}
+		return out, false
+	case strings.HasPrefix(mediaType, "video/"), strings.HasPrefix(mediaType, "audio/"):
+		out.Name = mediaType[:5]
+		out.Attrs["src"] = src
+		out.Attrs["controls"] = ""
+		out.Attrs["preload"] = "metadata"
+		if out.Name == "video" {
+			setDimensions(out, attachment)
+		}
+		return out.AppendChild(link), false
+	}
+	link.Attrs["download"] = attachment.Filename
+	link.Attrs["class"] = "attachment"
+	return link, false
+}
+
+func setDimensions(out *HTMLTag, attachment Attachment) {
+	if attachment.Width > 0 && attachment.Height > 0 {
+		out.Attrs["width"] = strconv.Itoa(attachment.Width)
+		out.Attrs["height"] = strconv.Itoa(attachment.Height)
+	}
+}
+
+func attachmentPlaceholder(kind, text string) *HTMLTag {
+	out := NewHTMLTag("")
+	out.Name = "span"
+	out.Attrs["class"] = "attachment attachment-" + kind
+	return out.AppendChild(NewHTMLTag(text))
+}
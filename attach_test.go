@@ -0,0 +1,126 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"context"
+	"testing"
+)
+
+var testAttachments = map[string]Attachment{
+	"1": {URL: "/files/cat.png", MIMEType: "image/png", Filename: "cat.png", Width: 640, Height: 480},
+	"2": {URL: "/files/dog.jpg", MIMEType: "image/jpeg", Filename: "dog.jpg", Width: 800, Height: 600, ThumbnailURL: "/thumbs/dog.jpg"},
+	"3": {URL: "/files/clip.webm", MIMEType: "video/webm", Filename: "clip.webm", Width: 320, Height: 240},
+	"4": {URL: "/files/song.ogg", MIMEType: "Audio/Ogg", Filename: "song.ogg"},
+	"5": {URL: "/files/notes.pdf", MIMEType: "application/pdf", Filename: "notes <1>.pdf"},
+	"6": {URL: "/files/secret.png", MIMEType: "image/png", Filename: "secret.png"},
+}
+
+var attachTests = map[string]string{
+	"[attach]1[/attach]":            `<img alt="cat.png" height="480" loading="lazy" src="/files/cat.png" width="640">`,
+	"[attach=2][/attach]":           `<a href="/files/dog.jpg"><img alt="dog.jpg" loading="lazy" src="/thumbs/dog.jpg"></a>`,
+	"[attach=2 type=full][/attach]": `<img alt="dog.jpg" height="600" loading="lazy" src="/files/dog.jpg" width="800">`,
+	"[attach]3[/attach]":            `<video controls="" height="240" preload="metadata" src="/files/clip.webm" width="320"><a href="/files/clip.webm">clip.webm</a></video>`,
+	"[attach] 4 [/attach]":          `<audio controls="" preload="metadata" src="/files/song.ogg"><a href="/files/song.ogg">song.ogg</a></audio>`,
+	"[attach]5[/attach]":            `<a class="attachment" download="notes &lt;1&gt;.pdf" href="/files/notes.pdf">notes &lt;1&gt;.pdf</a>`,
+	"[attach]6[/attach]":            `<span class="attachment attachment-forbidden">You do not have permission to view this attachment</span>`,
+	"[attach]7[/attach]":            `<span class="attachment attachment-missing">Attachment unavailable</span>`,
+}
+
+type staffKey struct{}
+
+func attachmentResolver() AttachmentResolver {
+	return AttachmentResolverFunc(func(ctx context.Context, id string) (Attachment, bool) {
+		attachment, ok := testAttachments[id]
+		if id == "6" && ctx.Value(staffKey{}) == nil {
+			attachment.Forbidden = true
+		}
+		return attachment, ok
+	})
+}
+
+func TestAttachments(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	if result := c.Compile("[attach]1[/attach]"); result != "[attach]1[/attach]" {
+		t.Errorf("Compiled attachment without a resolver: %s\n", result)
+	}
+
+	c.Attachments = attachmentResolver()
+	for in, out := range attachTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
+
+func TestAttachmentsPerViewer(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	c.Attachments = attachmentResolver()
+	doc := c.Prepare("[b]x[/b] [attach]6[/attach]")
+	staff := context.WithValue(context.Background(), staffKey{}, true)
+	tests := []struct {
+		ctx context.Context
+		out string
+	}{
+		{context.Background(), `<b>x</b> <span class="attachment attachment-forbidden">You do not have permission to view this attachment</span>`},
+		{staff, `<b>x</b> <img alt="secret.png" loading="lazy" src="/files/secret.png">`},
+	}
+	for _, test := range tests {
+		if result := doc.Render(test.ctx); result != test.out {
+			t.Errorf("Failed to render attachment.\nExpected: %s, got: %s\n", test.out, result)
+		}
+	}
+}
+
+var unclosedAttachTests = map[string]string{
+	"see [attach=1 type=full] and more":      `see <img alt="cat.png" height="480" loading="lazy" src="/files/cat.png" width="640"> and more`,
+	"[attach=1] [b]bold[/b] http://x.com :)": `<img alt="cat.png" height="480" loading="lazy" src="/files/cat.png" width="640"> <b>bold</b> <a href="http://x.com">http://x.com</a> :)`,
+	"[attach=2][attach=7] after":             `<a href="/files/dog.jpg"><img alt="dog.jpg" loading="lazy" src="/thumbs/dog.jpg"></a><span class="attachment attachment-missing">Attachment unavailable</span> after`,
+	"[attach=1][/attach] after":              `<img alt="cat.png" height="480" loading="lazy" src="/files/cat.png" width="640"> after`,
+	"[b][attach=7] x[/b]":                    `<b><span class="attachment attachment-missing">Attachment unavailable</span> x</b>`,
+}
+
+func TestUnclosedAttachments(t *testing.T) {
+	for _, autoClose := range []bool{false, true} {
+		c := NewCompiler(autoClose, false)
+		c.SortOutputAttributes = true
+		c.AutoLink = true
+		c.Attachments = attachmentResolver()
+		for in, out := range unclosedAttachTests {
+			result := c.Compile(in)
+			if result != out {
+				t.Errorf("Failed to compile %s with AutoCloseTags %v.\nExpected: %s, got: %s\n", in, autoClose, out, result)
+			}
+		}
+		if result := c.Compile("[attach]1"); result != "[attach]1" && !autoClose {
+			t.Errorf("Compiled unclosed attachment without a value: %s\n", result)
+		}
+	}
+}
+
+var paragraphAttachTests = map[string]string{
+	"[attach=1] hello\n\nworld":                `<p><img alt="cat.png" height="480" loading="lazy" src="/files/cat.png" width="640"> hello</p><p>world</p>`,
+	"a\n\n[attach=7]\n\n[quote]q[/quote]\nb":   `<p>a</p><p><span class="attachment attachment-missing">Attachment unavailable</span></p><blockquote><cite>Quote</cite><p>q</p></blockquote><p>b</p>`,
+	"[attach]1[/attach]\n\nafter http://x.com": `<p><img alt="cat.png" height="480" loading="lazy" src="/files/cat.png" width="640"></p><p>after <a href="http://x.com">http://x.com</a></p>`,
+}
+
+func TestParagraphAttachments(t *testing.T) {
+	for _, autoClose := range []bool{false, true} {
+		c := NewCompiler(autoClose, false)
+		c.SortOutputAttributes = true
+		c.Paragraphs = true
+		c.AutoLink = true
+		c.Attachments = attachmentResolver()
+		for in, out := range paragraphAttachTests {
+			result := c.Compile(in)
+			if result != out {
+				t.Errorf("Failed to compile %s with AutoCloseTags %v.\nExpected: %s, got: %s\n", in, autoClose, out, result)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var autoLinkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+|\b[a-z0-9._%+-]+@[a-z0-9-]+(?:\.[a-z0-9-]+)*\.[a-z]{2,}\b`)
+
+// findLinks returns the bare URLs and email addresses in text, as pairs of
+// start and end offsets, along with the href for each.
+func findLinks(text string) ([][2]int, []string) {
+	var spans [][2]int
+	var hrefs []string
+	for _, loc := range autoLinkPattern.FindAllStringIndex(text, -1) {
+		match := text[loc[0]:loc[1]]
+		lower := strings.ToLower(match)
+		var href string
+		if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "www.") {
+			match = trimLink(match)
+			href = match
+			if strings.HasPrefix(lower, "www.") {
+				href = "http://" + match
+			}
+			if u, err := url.Parse(href); err != nil || u.Host == "" || !strings.Contains(u.Host, ".") {
+				continue
+			}
+		} else {
+			href = "mailto:" + match
+		}
+		spans = append(spans, [2]int{loc[0], loc[0] + len(match)})
+		hrefs = append(hrefs, href)
+	}
+	return spans, hrefs
+}
+
+// trimLink removes trailing punctuation from a URL, keeping closing
+// brackets that are balanced within the URL.
+func trimLink(s string) string {
+	for len(s) > 0 {
+		last := s[len(s)-1]
+		switch last {
+		case '.', ',', ':', ';', '!', '?', '\'', '"', '*':
+			s = s[:len(s)-1]
+		case ')', ']', '}':
+			open := map[byte]byte{')': '(', ']': '[', '}': '{'}[last]
+			if strings.Count(s, string(open)) >= strings.Count(s, string(last)) {
+				return s
+			}
+			s = s[:len(s)-1]
+		default:
+			return s
+		}
+	}
+	return s
+}
+
+// inVerbatim reports whether node is inside a tag marked as Verbatim.
+func (c Compiler) inVerbatim(node *BBCodeNode) bool {
+	for n := node; n != nil; n = n.Parent {
+		if n.ID == OPENING_TAG && (n.ClosingTag != nil || c.AutoCloseTags) && !c.selfClosed(n) && c.tagOptions[n.Value.(BBOpeningTag).Name].Verbatim {
+			return true
+		}
+	}
+	return false
+}
+
+// autoLink splits text into text and links, compiling bare URLs and email
+// addresses with the url tag.
+func (c Compiler) autoLink(node *BBCodeNode, text string) []*HTMLTag {
+	var out []*HTMLTag
+	pos := 0
+	// The links are made for each compile, so their output isn't cached.
+	c.cache = nil
+	spans, hrefs := findLinks(text)
+	for i, span := range spans {
+		if span[0] > pos {
+			out = append(out, NewHTMLTag(text[pos:span[0]]))
+		}
+		link := NewTagNode("url", hrefs[i], nil)
+		link.AppendChild(NewTextNode(text[span[0]:span[1]]))
+		// The link is not added to node's children, but is compiled in its
+		// context.
+		link.Parent = node
+		out = append(out, c.CompileTree(link))
+		pos = span[1]
+	}
+	if pos < len(text) {
+		out = append(out, NewHTMLTag(text[pos:]))
+	}
+	return out
+}
@@ -17,6 +17,7 @@ type BBOpeningTag struct {
 type BBClosingTag struct {
 	Name string
 	Raw  string
+	Pos  int // Byte offset of the tag in the input.
 }
 
 func (t *BBOpeningTag) String() string {
@@ -0,0 +1,339 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// BinaryVersion is the version of the encoding written by MarshalBinary.
+//
+// An encoded tree starts with the magic bytes "BBC" and the version byte,
+// followed by a table of the tag and argument names used in the tree, and
+// then the root node. Integers are unsigned varints, strings are written as
+// their length followed by their bytes, and names are written as indexes
+// into the table. A node is written as:
+//
+//	kind     1 byte: 0 for text, 1 for an opening tag, 2 for an unmatched
+//	         closing tag. 0x80 is set on opening tags that are closed,
+//	         0x40 when the raw source of an opening tag is left out, and
+//	         0x20 when the raw source of a closing tag is left out.
+//	pos      The byte offset of the node in the input.
+//	text     Text nodes: the text.
+//	tag      Opening tags: name, value, argument count, each argument's
+//	         name and value sorted by name, raw source, and if closed the
+//	         raw source and position of the closing tag.
+//	         Closing tags: name and raw source.
+//	children The number of children, followed by the children.
+//
+// Raw source is left out when it is the tag as BBOpeningTag.String writes
+// it, in brackets, or [/name] for closing tags.
+const BinaryVersion = 1
+
+const binaryMagic = "BBC"
+
+const (
+	binaryText    = 0
+	binaryOpening = 1
+	binaryClosing = 2
+	binaryClosed  = 0x80
+
+	binaryOpeningRaw = 0x40
+	binaryClosingRaw = 0x20
+	binaryFlags      = binaryClosed | binaryOpeningRaw | binaryClosingRaw
+)
+
+var errBinaryTruncated = errors.New("bbcode: binary tree is truncated")
+
+// MarshalBinary encodes the tree under n. Like MarshalJSON, it leaves out
+// the Compiler and Info fields of the nodes.
+func (n *BBCodeNode) MarshalBinary() ([]byte, error) {
+	e := &binaryEncoder{names: make(map[string]uint64)}
+	if err := e.node(n); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(e.body)+len(binaryMagic)+16*len(e.table)+8)
+	out = append(out, binaryMagic...)
+	out = append(out, BinaryVersion)
+	out = binary.AppendUvarint(out, uint64(len(e.table)))
+	for _, name := range e.table {
+		out = appendBinaryString(out, name)
+	}
+	return append(out, e.body...), nil
+}
+
+// UnmarshalBinary decodes a tree written by MarshalBinary into n, which
+// becomes its root.
+func (n *BBCodeNode) UnmarshalBinary(data []byte) error {
+	if len(data) < len(binaryMagic)+1 || string(data[:len(binaryMagic)]) != binaryMagic {
+		return errors.New("bbcode: not a binary tree")
+	}
+	if version := data[len(binaryMagic)]; version < 1 || version > BinaryVersion {
+		return fmt.Errorf("bbcode: unsupported binary version %d", version)
+	}
+	d := &binaryDecoder{data: data[len(binaryMagic)+1:]}
+	count := d.count()
+	d.table = make([]string, 0, count)
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		d.table = append(d.table, d.string())
+	}
+	root := d.node(nil)
+	if d.err == nil && len(d.data) > 0 {
+		d.err = errors.New("bbcode: unexpected data after binary tree")
+	}
+	if d.err != nil {
+		return d.err
+	}
+	*n = *root
+	for _, child := range n.Children {
+		child.Parent = n
+	}
+	return nil
+}
+
+// CompileBinary renders a tree encoded with MarshalBinary, without parsing
+// its source again.
+func (c Compiler) CompileBinary(data []byte) (string, error) {
+	var tree BBCodeNode
+	if err := tree.UnmarshalBinary(data); err != nil {
+		return "", err
+	}
+	return c.render(&tree), nil
+}
+
+type binaryEncoder struct {
+	names map[string]uint64
+	table []string
+	body  []byte
+}
+
+func appendBinaryString(out []byte, s string) []byte {
+	out = binary.AppendUvarint(out, uint64(len(s)))
+	return append(out, s...)
+}
+
+func (e *binaryEncoder) uint(v int) {
+	e.body = binary.AppendUvarint(e.body, uint64(v))
+}
+
+func (e *binaryEncoder) string(s string) {
+	e.body = appendBinaryString(e.body, s)
+}
+
+func (e *binaryEncoder) name(name string) {
+	i, ok := e.names[name]
+	if !ok {
+		i = uint64(len(e.table))
+		e.names[name] = i
+		e.table = append(e.table, name)
+	}
+	e.body = binary.AppendUvarint(e.body, i)
+}
+
+func (e *binaryEncoder) node(n *BBCodeNode) error {
+	if n.Pos < 0 {
+		return fmt.Errorf("bbcode: can't marshal negative position %d", n.Pos)
+	}
+	switch value := n.Value.(type) {
+	case string:
+		e.body = append(e.body, binaryText)
+		e.uint(n.Pos)
+		e.string(value)
+	case BBOpeningTag:
+		kind := byte(binaryOpening)
+		if value.Raw == "["+value.String()+"]" {
+			kind |= binaryOpeningRaw
+		}
+		if n.ClosingTag != nil {
+			kind |= binaryClosed
+			if n.ClosingTag.Raw == "[/"+value.Name+"]" {
+				kind |= binaryClosingRaw
+			}
+		}
+		e.body = append(e.body, kind)
+		e.uint(n.Pos)
+		e.name(value.Name)
+		e.string(value.Value)
+		keys := make([]string, 0, len(value.Args))
+		for key := range value.Args {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		e.uint(len(keys))
+		for _, key := range keys {
+			e.name(key)
+			e.string(value.Args[key])
+		}
+		if kind&binaryOpeningRaw == 0 {
+			e.string(value.Raw)
+		}
+		if n.ClosingTag != nil {
+			if kind&binaryClosingRaw == 0 {
+				e.string(n.ClosingTag.Raw)
+			}
+			e.uint(n.ClosingTag.Pos)
+		}
+	case BBClosingTag:
+		kind := byte(binaryClosing)
+		if value.Raw == "[/"+value.Name+"]" {
+			kind |= binaryClosingRaw
+		}
+		e.body = append(e.body, kind)
+		e.uint(n.Pos)
+		e.name(value.Name)
+		if kind&binaryClosingRaw == 0 {
+			e.string(value.Raw)
+		}
+	default:
+		return fmt.Errorf("bbcode: can't marshal %s node with value %T", n.ID, n.Value)
+	}
+	e.uint(len(n.Children))
+	for _, child := range n.Children {
+		if err := e.node(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// binaryDecoder reads from data, recording the first error in err.
+type binaryDecoder struct {
+	data  []byte
+	table []string
+	err   error
+}
+
+func (d *binaryDecoder) uint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, size := binary.Uvarint(d.data)
+	if size <= 0 {
+		d.err = errBinaryTruncated
+		return 0
+	}
+	d.data = d.data[size:]
+	return v
+}
+
+// count reads a number of items, each of which takes at least one byte.
+func (d *binaryDecoder) count() uint64 {
+	v := d.uint()
+	if v > uint64(len(d.data)) {
+		d.err = errBinaryTruncated
+		return 0
+	}
+	return v
+}
+
+func (d *binaryDecoder) pos() int {
+	v := d.uint()
+	if int(v) < 0 || uint64(int(v)) != v {
+		d.err = errors.New("bbcode: binary position out of range")
+		return 0
+	}
+	return int(v)
+}
+
+func (d *binaryDecoder) string() string {
+	n := d.count()
+	if d.err != nil {
+		return ""
+	}
+	s := string(d.data[:n])
+	d.data = d.data[n:]
+	return s
+}
+
+func (d *binaryDecoder) name() string {
+	i := d.uint()
+	if d.err == nil && i >= uint64(len(d.table)) {
+		d.err = errors.New("bbcode: binary name index out of range")
+	}
+	if d.err != nil {
+		return ""
+	}
+	return d.table[i]
+}
+
+func (d *binaryDecoder) closingRaw(kind byte, name string) string {
+	if kind&binaryClosingRaw != 0 {
+		return "[/" + name + "]"
+	}
+	return d.string()
+}
+
+// validBinaryKind reports whether kind is a node type with flags that apply
+// to it.
+func validBinaryKind(kind byte) bool {
+	switch kind &^ binaryFlags {
+	case binaryText:
+		return kind == binaryText
+	case binaryOpening:
+		return kind&binaryClosingRaw == 0 || kind&binaryClosed != 0
+	case binaryClosing:
+		return kind&^binaryClosingRaw == binaryClosing
+	}
+	return false
+}
+
+func (d *binaryDecoder) node(parent *BBCodeNode) *BBCodeNode {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.data) == 0 {
+		d.err = errBinaryTruncated
+		return nil
+	}
+	kind := d.data[0]
+	d.data = d.data[1:]
+	if !validBinaryKind(kind) {
+		d.err = fmt.Errorf("bbcode: unknown binary node kind %d", kind)
+		return nil
+	}
+	n := &BBCodeNode{Parent: parent}
+	n.Pos = d.pos()
+	switch kind &^ binaryFlags {
+	case binaryText:
+		n.ID = TEXT
+		n.Value = d.string()
+	case binaryOpening:
+		n.ID = OPENING_TAG
+		tag := BBOpeningTag{Name: d.name(), Value: d.string()}
+		args := d.count()
+		tag.Args = make(map[string]string, args)
+		for i := uint64(0); i < args && d.err == nil; i++ {
+			key := d.name()
+			tag.Args[key] = d.string()
+		}
+		if kind&binaryOpeningRaw != 0 {
+			tag.Raw = "[" + tag.String() + "]"
+		} else {
+			tag.Raw = d.string()
+		}
+		n.Value = tag
+		if kind&binaryClosed != 0 {
+			n.ClosingTag = &BBClosingTag{Name: tag.Name, Raw: d.closingRaw(kind, tag.Name)}
+			n.ClosingTag.Pos = d.pos()
+		}
+	case binaryClosing:
+		n.ID = CLOSING_TAG
+		closing := BBClosingTag{Name: d.name(), Pos: n.Pos}
+		closing.Raw = d.closingRaw(kind, closing.Name)
+		n.Value = closing
+	}
+	children := d.count()
+	n.Children = make([]*BBCodeNode, 0, children)
+	for i := uint64(0); i < children && d.err == nil; i++ {
+		n.Children = append(n.Children, d.node(n))
+	}
+	if d.err != nil {
+		return nil
+	}
+	return n
+}
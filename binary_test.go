@@ -0,0 +1,138 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// bbcodeInput generates random input made of tags and text.
+type bbcodeInput string
+
+var inputPieces = []string{
+	"[b]", "[/b]", "[i]", "[/I]", "[url=http://example.com]", "[/url]", "[img]", "[/img]",
+	"[quote name=\"a b\" post=1]", "[/quote]", "[code]", "[/code]", "[color=red]", "[/color]",
+	"[size=5 x]", "[/size]", "[", "]", "=", "text", " ", "\n", "\n\n", "é", "http://a.com", "[/",
+}
+
+func (bbcodeInput) Generate(r *rand.Rand, size int) reflect.Value {
+	var pieces []string
+	for i := r.Intn(size + 1); i > 0; i-- {
+		pieces = append(pieces, inputPieces[r.Intn(len(inputPieces))])
+	}
+	return reflect.ValueOf(bbcodeInput(strings.Join(pieces, "")))
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	compilers := []Compiler{NewCompiler(false, false), NewCompiler(true, true)}
+	compilers[1].Paragraphs = true
+	compilers[1].AutoLink = true
+	roundTrip := func(in bbcodeInput) bool {
+		tree := Parse(Lex(string(in)))
+		data, err := tree.MarshalBinary()
+		if err != nil {
+			t.Logf("Failed to marshal %q: %s", in, err)
+			return false
+		}
+		var decoded BBCodeNode
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Logf("Failed to unmarshal %q: %s", in, err)
+			return false
+		}
+		expected, _ := json.Marshal(tree)
+		result, _ := json.Marshal(&decoded)
+		if !bytes.Equal(expected, result) {
+			t.Logf("Round trip of %q changed the tree.\nExpected: %s, got: %s", in, expected, result)
+			return false
+		}
+		for _, c := range compilers {
+			c.SortOutputAttributes = true
+			html, err := c.CompileBinary(data)
+			if err != nil || html != c.Compile(string(in)) {
+				t.Logf("Failed to compile %q from binary: %s %v", in, html, err)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBinaryErrors(t *testing.T) {
+	data, err := Parse(Lex("a[b=1 x=y]b[/b][/i]")).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(data); i++ {
+		var node BBCodeNode
+		if err := node.UnmarshalBinary(data[:i]); err == nil {
+			t.Errorf("Failed to reject truncated data %v\n", data[:i])
+		}
+	}
+	tests := map[string][]byte{
+		"not a binary tree":            []byte("JSON"),
+		"unsupported binary version":   append([]byte(binaryMagic), 9),
+		"unexpected data":              append(append([]byte{}, data...), 0),
+		"name index out of range":      append([]byte(binaryMagic), 1, 0, binaryClosing, 0, 0),
+		"unknown binary node kind 3":   append([]byte(binaryMagic), 1, 0, 3, 0, 0),
+		"unknown binary node kind 128": append([]byte(binaryMagic), 1, 0, binaryClosed, 0, 0, 0),
+		"unknown binary node kind 33":  append([]byte(binaryMagic), 1, 1, 1, 'b', binaryOpening|binaryClosingRaw, 0, 0, 0, 0, 0, 0),
+		"unknown binary node kind 66":  append([]byte(binaryMagic), 1, 1, 1, 'b', binaryClosing|binaryOpeningRaw, 0, 0, 0),
+	}
+	for message, in := range tests {
+		var node BBCodeNode
+		if err := node.UnmarshalBinary(in); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Failed to reject %v.\nExpected: %s, got: %v\n", in, message, err)
+		}
+	}
+}
+
+var benchmarkInput = strings.Repeat("[quote name=someone]Hello [b]world[/b], see [url=http://example.com/page]this page[/url] "+
+	"and [img]http://example.com/image.png[/img].\n[color=red]Red [i]italic[/i][/color] [size=5]big[/size][/quote]\n", 20)
+
+func BenchmarkCompileSource(b *testing.B) {
+	c := NewCompiler(true, true)
+	b.SetBytes(int64(len(benchmarkInput)))
+	for i := 0; i < b.N; i++ {
+		c.Compile(benchmarkInput)
+	}
+}
+
+func BenchmarkCompileBinary(b *testing.B) {
+	c := NewCompiler(true, true)
+	data, _ := Parse(Lex(benchmarkInput)).MarshalBinary()
+	jsonData, _ := json.Marshal(Parse(Lex(benchmarkInput)))
+	b.SetBytes(int64(len(benchmarkInput)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.CompileBinary(data)
+	}
+	b.ReportMetric(float64(len(data)), "binary-bytes")
+	b.ReportMetric(float64(len(jsonData)), "json-bytes")
+}
+
+func BenchmarkUnmarshalBinary(b *testing.B) {
+	data, _ := Parse(Lex(benchmarkInput)).MarshalBinary()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		var tree BBCodeNode
+		tree.UnmarshalBinary(data)
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkInput)))
+	for i := 0; i < b.N; i++ {
+		Parse(Lex(benchmarkInput))
+	}
+}
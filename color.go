@@ -0,0 +1,355 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Color is a CSS color value accepted by the color tag.
+type Color struct {
+	R, G, B uint8
+	A       float64
+	css     string
+}
+
+// String returns the normalized CSS representation of the color.
+func (c Color) String() string {
+	return c.css
+}
+
+// ParseColor parses a CSS color. Named colors, #rgb, #rgba, #rrggbb,
+// #rrggbbaa, rgb(), rgba(), hsl() and hsla() are accepted, and every
+// component must be within its valid range.
+func ParseColor(s string) (Color, bool) {
+	s = strings.TrimSpace(s)
+	lower := strings.ToLower(s)
+	if v, ok := namedColors[lower]; ok {
+		return Color{uint8(v >> 16), uint8(v >> 8), uint8(v), 1, lower}, true
+	}
+	if strings.HasPrefix(s, "#") {
+		return parseHex(s)
+	}
+	open := strings.IndexByte(lower, '(')
+	if open < 0 || !strings.HasSuffix(lower, ")") {
+		return Color{}, false
+	}
+	args := strings.Split(lower[open+1:len(lower)-1], ",")
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	switch lower[:open] {
+	case "rgb", "rgba":
+		return parseRGB(args)
+	case "hsl", "hsla":
+		return parseHSL(args)
+	}
+	return Color{}, false
+}
+
+func parseHex(s string) (Color, bool) {
+	digits := s[1:]
+	if len(digits) == 3 || len(digits) == 4 {
+		expanded := make([]byte, 0, 8)
+		for i := 0; i < len(digits); i++ {
+			expanded = append(expanded, digits[i], digits[i])
+		}
+		digits = string(expanded)
+	}
+	if len(digits) != 6 && len(digits) != 8 {
+		return Color{}, false
+	}
+	v, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return Color{}, false
+	}
+	alpha := 1.0
+	if len(digits) == 8 {
+		alpha = float64(v&0xff) / 255
+		v >>= 8
+	}
+	return Color{uint8(v >> 16), uint8(v >> 8), uint8(v), alpha, s}, true
+}
+
+func parseRGB(args []string) (Color, bool) {
+	if len(args) != 3 && len(args) != 4 {
+		return Color{}, false
+	}
+	var rgb [3]uint8
+	for i := 0; i < 3; i++ {
+		if strings.HasSuffix(args[i], "%") {
+			p, ok := parseNumber(args[i][:len(args[i])-1], 0, 100)
+			if !ok {
+				return Color{}, false
+			}
+			rgb[i] = uint8(p*255/100 + 0.5)
+		} else {
+			v, ok := parseNumber(args[i], 0, 255)
+			if !ok {
+				return Color{}, false
+			}
+			rgb[i] = uint8(v + 0.5)
+		}
+	}
+	c := Color{R: rgb[0], G: rgb[1], B: rgb[2], A: 1}
+	components := formatInt(c.R) + ", " + formatInt(c.G) + ", " + formatInt(c.B)
+	if len(args) == 4 {
+		a, ok := parseAlpha(args[3])
+		if !ok {
+			return Color{}, false
+		}
+		c.A = a
+		c.css = "rgba(" + components + ", " + formatFloat(a) + ")"
+	} else {
+		c.css = "rgb(" + components + ")"
+	}
+	return c, true
+}
+
+func parseHSL(args []string) (Color, bool) {
+	if len(args) != 3 && len(args) != 4 {
+		return Color{}, false
+	}
+	h, ok := parseNumber(strings.TrimSuffix(args[0], "deg"), 0, 360)
+	if !ok || !strings.HasSuffix(args[1], "%") || !strings.HasSuffix(args[2], "%") {
+		return Color{}, false
+	}
+	sat, ok := parseNumber(args[1][:len(args[1])-1], 0, 100)
+	if !ok {
+		return Color{}, false
+	}
+	light, ok := parseNumber(args[2][:len(args[2])-1], 0, 100)
+	if !ok {
+		return Color{}, false
+	}
+	r, g, b := hslToRGB(h/360, sat/100, light/100)
+	c := Color{R: r, G: g, B: b, A: 1}
+	components := formatFloat(h) + ", " + formatFloat(sat) + "%, " + formatFloat(light) + "%"
+	if len(args) == 4 {
+		a, ok := parseAlpha(args[3])
+		if !ok {
+			return Color{}, false
+		}
+		c.A = a
+		c.css = "hsla(" + components + ", " + formatFloat(a) + ")"
+	} else {
+		c.css = "hsl(" + components + ")"
+	}
+	return c, true
+}
+
+func parseAlpha(s string) (float64, bool) {
+	if strings.HasSuffix(s, "%") {
+		p, ok := parseNumber(s[:len(s)-1], 0, 100)
+		return p / 100, ok
+	}
+	return parseNumber(s, 0, 1)
+}
+
+// parseNumber parses a plain decimal number within [min, max]. Exponents,
+// infinities and NaN are rejected.
+func parseNumber(s string, min, max float64) (float64, bool) {
+	if s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != '-' && r != '+'
+	}) >= 0 {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < min || v > max {
+		return 0, false
+	}
+	return v, true
+}
+
+func hslToRGB(h, s, l float64) (uint8, uint8, uint8) {
+	var q float64
+	if l < 0.5 {
+		q = l * (1 + s)
+	} else {
+		q = l + s - l*s
+	}
+	p := 2*l - q
+	hue := func(t float64) uint8 {
+		if t < 0 {
+			t++
+		} else if t > 1 {
+			t--
+		}
+		var v float64
+		switch {
+		case t < 1.0/6:
+			v = p + (q-p)*6*t
+		case t < 0.5:
+			v = q
+		case t < 2.0/3:
+			v = p + (q-p)*(2.0/3-t)*6
+		default:
+			v = p
+		}
+		return uint8(v*255 + 0.5)
+	}
+	return hue(h + 1.0/3), hue(h), hue(h - 1.0/3)
+}
+
+func formatInt(v uint8) string {
+	return strconv.Itoa(int(v))
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// namedColors contains the CSS named colors.
+var namedColors = map[string]uint32{
+	"aliceblue":            0xf0f8ff,
+	"antiquewhite":         0xfaebd7,
+	"aqua":                 0x00ffff,
+	"aquamarine":           0x7fffd4,
+	"azure":                0xf0ffff,
+	"beige":                0xf5f5dc,
+	"bisque":               0xffe4c4,
+	"black":                0x000000,
+	"blanchedalmond":       0xffebcd,
+	"blue":                 0x0000ff,
+	"blueviolet":           0x8a2be2,
+	"brown":                0xa52a2a,
+	"burlywood":            0xdeb887,
+	"cadetblue":            0x5f9ea0,
+	"chartreuse":           0x7fff00,
+	"chocolate":            0xd2691e,
+	"coral":                0xff7f50,
+	"cornflowerblue":       0x6495ed,
+	"cornsilk":             0xfff8dc,
+	"crimson":              0xdc143c,
+	"cyan":                 0x00ffff,
+	"darkblue":             0x00008b,
+	"darkcyan":             0x008b8b,
+	"darkgoldenrod":        0xb8860b,
+	"darkgray":             0xa9a9a9,
+	"darkgreen":            0x006400,
+	"darkgrey":             0xa9a9a9,
+	"darkkhaki":            0xbdb76b,
+	"darkmagenta":          0x8b008b,
+	"darkolivegreen":       0x556b2f,
+	"darkorange":           0xff8c00,
+	"darkorchid":           0x9932cc,
+	"darkred":              0x8b0000,
+	"darksalmon":           0xe9967a,
+	"darkseagreen":         0x8fbc8f,
+	"darkslateblue":        0x483d8b,
+	"darkslategray":        0x2f4f4f,
+	"darkslategrey":        0x2f4f4f,
+	"darkturquoise":        0x00ced1,
+	"darkviolet":           0x9400d3,
+	"deeppink":             0xff1493,
+	"deepskyblue":          0x00bfff,
+	"dimgray":              0x696969,
+	"dimgrey":              0x696969,
+	"dodgerblue":           0x1e90ff,
+	"firebrick":            0xb22222,
+	"floralwhite":          0xfffaf0,
+	"forestgreen":          0x228b22,
+	"fuchsia":              0xff00ff,
+	"gainsboro":            0xdcdcdc,
+	"ghostwhite":           0xf8f8ff,
+	"gold":                 0xffd700,
+	"goldenrod":            0xdaa520,
+	"gray":                 0x808080,
+	"green":                0x008000,
+	"greenyellow":          0xadff2f,
+	"grey":                 0x808080,
+	"honeydew":             0xf0fff0,
+	"hotpink":              0xff69b4,
+	"indianred":            0xcd5c5c,
+	"indigo":               0x4b0082,
+	"ivory":                0xfffff0,
+	"khaki":                0xf0e68c,
+	"lavender":             0xe6e6fa,
+	"lavenderblush":        0xfff0f5,
+	"lawngreen":            0x7cfc00,
+	"lemonchiffon":         0xfffacd,
+	"lightblue":            0xadd8e6,
+	"lightcoral":           0xf08080,
+	"lightcyan":            0xe0ffff,
+	"lightgoldenrodyellow": 0xfafad2,
+	"lightgray":            0xd3d3d3,
+	"lightgreen":           0x90ee90,
+	"lightgrey":            0xd3d3d3,
+	"lightpink":            0xffb6c1,
+	"lightsalmon":          0xffa07a,
+	"lightseagreen":        0x20b2aa,
+	"lightskyblue":         0x87cefa,
+	"lightslategray":       0x778899,
+	"lightslategrey":       0x778899,
+	"lightsteelblue":       0xb0c4de,
+	"lightyellow":          0xffffe0,
+	"lime":                 0x00ff00,
+	"limegreen":            0x32cd32,
+	"linen":                0xfaf0e6,
+	"magenta":              0xff00ff,
+	"maroon":               0x800000,
+	"mediumaquamarine":     0x66cdaa,
+	"mediumblue":           0x0000cd,
+	"mediumorchid":         0xba55d3,
+	"mediumpurple":         0x9370db,
+	"mediumseagreen":       0x3cb371,
+	"mediumslateblue":      0x7b68ee,
+	"mediumspringgreen":    0x00fa9a,
+	"mediumturquoise":      0x48d1cc,
+	"mediumvioletred":      0xc71585,
+	"midnightblue":         0x191970,
+	"mintcream":            0xf5fffa,
+	"mistyrose":            0xffe4e1,
+	"moccasin":             0xffe4b5,
+	"navajowhite":          0xffdead,
+	"navy":                 0x000080,
+	"oldlace":              0xfdf5e6,
+	"olive":                0x808000,
+	"olivedrab":            0x6b8e23,
+	"orange":               0xffa500,
+	"orangered":            0xff4500,
+	"orchid":               0xda70d6,
+	"palegoldenrod":        0xeee8aa,
+	"palegreen":            0x98fb98,
+	"paleturquoise":        0xafeeee,
+	"palevioletred":        0xdb7093,
+	"papayawhip":           0xffefd5,
+	"peachpuff":            0xffdab9,
+	"peru":                 0xcd853f,
+	"pink":                 0xffc0cb,
+	"plum":                 0xdda0dd,
+	"powderblue":           0xb0e0e6,
+	"purple":               0x800080,
+	"rebeccapurple":        0x663399,
+	"red":                  0xff0000,
+	"rosybrown":            0xbc8f8f,
+	"royalblue":            0x4169e1,
+	"saddlebrown":          0x8b4513,
+	"salmon":               0xfa8072,
+	"sandybrown":           0xf4a460,
+	"seagreen":             0x2e8b57,
+	"seashell":             0xfff5ee,
+	"sienna":               0xa0522d,
+	"silver":               0xc0c0c0,
+	"skyblue":              0x87ceeb,
+	"slateblue":            0x6a5acd,
+	"slategray":            0x708090,
+	"slategrey":            0x708090,
+	"snow":                 0xfffafa,
+	"springgreen":          0x00ff7f,
+	"steelblue":            0x4682b4,
+	"tan":                  0xd2b48c,
+	"teal":                 0x008080,
+	"thistle":              0xd8bfd8,
+	"tomato":               0xff6347,
+	"turquoise":            0x40e0d0,
+	"violet":               0xee82ee,
+	"wheat":                0xf5deb3,
+	"white":                0xffffff,
+	"whitesmoke":           0xf5f5f5,
+	"yellow":               0xffff00,
+	"yellowgreen":          0x9acd32,
+}
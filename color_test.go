@@ -0,0 +1,96 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import "testing"
+
+var colorTests = map[string]string{
+	"red":                    "red",
+	" DeepSkyBlue ":          "deepskyblue",
+	"#00BFFF":                "#00BFFF",
+	"#abc":                   "#abc",
+	"#abcd":                  "#abcd",
+	"#aabbccdd":              "#aabbccdd",
+	"rgb(255, 0, 0)":         "rgb(255, 0, 0)",
+	"RGB(255,0,0)":           "rgb(255, 0, 0)",
+	"rgb(100%, 50%, 0%)":     "rgb(255, 128, 0)",
+	"rgba(0, 0, 0, 0.5)":     "rgba(0, 0, 0, 0.5)",
+	"rgba(0, 0, 0, 50%)":     "rgba(0, 0, 0, 0.5)",
+	"hsl(120, 100%, 25%)":    "hsl(120, 100%, 25%)",
+	"hsla(120deg,50%,50%,1)": "hsla(120, 50%, 50%, 1)",
+}
+
+var invalidColorTests = []string{
+	"",
+	"url(x)",
+	"expression(alert(1))",
+	"red;;;",
+	"notacolor",
+	"#ab",
+	"#abcde",
+	"#gggggg",
+	"#-12345",
+	"rgb(256, 0, 0)",
+	"rgb(-1, 0, 0)",
+	"rgb(0, 0)",
+	"rgb(0, 0, 0, 0, 0)",
+	"rgb(1e2, 0, 0)",
+	"rgba(0, 0, 0, 2)",
+	"rgb(101%, 0%, 0%)",
+	"hsl(400, 50%, 50%)",
+	"hsl(120, 50, 50)",
+	"hsl(120, 150%, 50%)",
+	"cmyk(0, 0, 0, 0)",
+}
+
+func TestParseColor(t *testing.T) {
+	for in, out := range colorTests {
+		result, ok := ParseColor(in)
+		if !ok || result.String() != out {
+			t.Errorf("Failed to parse %s.\nExpected: %s, got: %s (%v)\n", in, out, result, ok)
+		}
+	}
+	for _, in := range invalidColorTests {
+		if result, ok := ParseColor(in); ok {
+			t.Errorf("Parsed invalid color %s as %s\n", in, result)
+		}
+	}
+}
+
+func TestParseColorComponents(t *testing.T) {
+	expected := map[string]Color{
+		"#0080ff":             {R: 0, G: 128, B: 255, A: 1},
+		"#0080ff80":           {R: 0, G: 128, B: 255, A: 128.0 / 255},
+		"hsl(0, 100%, 50%)":   {R: 255, G: 0, B: 0, A: 1},
+		"hsl(240, 100%, 50%)": {R: 0, G: 0, B: 255, A: 1},
+		"olive":               {R: 128, G: 128, B: 0, A: 1},
+	}
+	for in, out := range expected {
+		result, ok := ParseColor(in)
+		if !ok || result.R != out.R || result.G != out.G || result.B != out.B || result.A != out.A {
+			t.Errorf("Failed to parse %s.\nExpected: %+v, got: %+v\n", in, out, result)
+		}
+	}
+}
+
+var colorTagTests = map[string]string{
+	"[color=red]hello[/color]":                     `<span style="color: red;">hello</span>`,
+	`[color="rgb(0, 128, 0)"]hello[/color]`:        `<span style="color: rgb(0, 128, 0);">hello</span>`,
+	"[color=url(x)]hello[/color]":                  `<span>hello</span>`,
+	"[color=expression(alert(1))]hello[/color]":    `<span>hello</span>`,
+	"[color=red;;;]hello[/color]":                  `<span>hello</span>`,
+	`[color="red;background:url(x)"]hello[/color]`: `<span>hello</span>`,
+	"[color]hello[/color]":                         `<span>hello</span>`,
+}
+
+func TestColorTag(t *testing.T) {
+	c := NewCompiler(false, false)
+	for in, out := range colorTagTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
@@ -5,8 +5,7 @@
 package bbcode
 
 import (
-	"fmt"
-	"strconv"
+	"context"
 	"strings"
 )
 
@@ -14,31 +13,197 @@ type TagCompilerFunc func(*BBCodeNode) (*HTMLTag, bool)
 
 type Compiler struct {
 	tagCompilers               map[string]TagCompilerFunc
+	tagOptions                 map[string]TagOptions
+	textFilters                *textFilters
+	mediaProviders             map[string]*MediaProvider
+	mediaTags                  map[string]bool
 	defaultCompiler            TagCompilerFunc
 	AutoCloseTags              bool
 	IgnoreUnmatchedClosingTags bool
 	SortOutputAttributes       bool
+
+	// RepairCrossedTags makes Compile parse with ParseWithRepair, so
+	// formatting tags crossed by other tags keep their intended ranges.
+	RepairCrossedTags bool
+
+	// ImageProxy, if set, rewrites the src of [img] tags to go through an
+	// image proxy.
+	ImageProxy *ImageProxy
+
+	// ClassOutput makes tags output class names prefixed by ClassPrefix
+	// instead of inline styles, for pages served with a Content-Security-Policy
+	// that blocks them. Colors are mapped to classes through Palette.
+	ClassOutput bool
+	ClassPrefix string
+	Palette     ColorPalette
+
+	// SizePolicy controls the units and bounds accepted by [size].
+	SizePolicy SizePolicy
+
+	// Elements overrides the HTML elements output by the b, i, u and s tags.
+	Elements map[string]Element
+
+	// CodeTabWidth, if set, expands tabs in [code] and [icode] tags to
+	// spaces, with tab stops every CodeTabWidth columns.
+	CodeTabWidth int
+
+	// Highlighter, if set, highlights the contents of [code=lang] tags.
+	// BuiltinHighlighter supports a few common languages.
+	Highlighter Highlighter
+
+	// SpoilerSummary is the summary of [spoiler] and [hide] tags without
+	// a title. If empty, the spoiler message is used.
+	SpoilerSummary string
+
+	// QuoteResolver, if set, links the citations of [quote post=id] tags to
+	// the quoted posts, and formats the times of [quote time=unix] tags.
+	// Without it, times are formatted in UTC.
+	QuoteResolver QuoteResolver
+
+	// QuoteCite, if set, replaces DefaultQuoteCite in building the <cite>
+	// element of quotes. Returning nil leaves the citation out.
+	QuoteCite QuoteCiteFunc
+
+	// MaxQuoteDepth, if set, collapses quotes nested more than
+	// MaxQuoteDepth deep into a <details> element.
+	MaxQuoteDepth int
+
+	// Paragraphs enables paragraph mode. Text separated by blank lines is
+	// wrapped in <p> elements, and newlines next to block-level tags are
+	// dropped instead of being turned into <br> elements.
+	Paragraphs bool
+
+	// AutoLink turns bare URLs and email addresses in text into links, which
+	// are compiled by the url tag. It is implemented by AutoLinkFilter.
+	AutoLink bool
+
+	// Smileys maps smiley codes such as :) to their replacements. Codes
+	// are only replaced when they stand alone. It is implemented by
+	// SmileyFilter.
+	Smileys map[string]Smiley
+
+	// Attachments resolves the IDs in [attach] tags. Without it, [attach]
+	// tags are left as written.
+	Attachments AttachmentResolver
+
+	// Mentions and Hashtags resolve @mentions and #hashtags in text to
+	// links. They are implemented by MentionFilter.
+	Mentions MentionResolver
+	Hashtags HashtagResolver
+
+	// Messages, if set, replaces DefaultMessages as the text output by the
+	// default tags. WithMessages selects messages for a single compile.
+	Messages Messages
+
+	// PostCompile, if set, is called by Compile and CompileResult with the
+	// compiled HTML tree before it is serialized.
+	PostCompile func(root *HTMLTag)
+
+	result *Result
+	ctx    context.Context
+	cache  *renderCache
+}
+
+// TagOptions describes how a tag interacts with the text around it.
+type TagOptions struct {
+	// Block marks tags that compile to block-level elements. In paragraph
+	// mode they are kept out of paragraphs, and their contents are split
+	// into paragraphs of their own.
+	Block bool
+	// Verbatim marks tags whose text is left as written, without running
+	// it through the text filters.
+	Verbatim bool
+	// ViewerDependent marks tags whose output depends on the viewer, read
+	// from Compiler.Context. Documents render them again for each viewer,
+	// and reuse the output of everything else, including text filters.
+	ViewerDependent bool
+	// SelfClosing marks tags that may be written without a closing tag when
+	// they have a value, such as [attach=123]. Content after an unclosed
+	// tag is compiled after it rather than inside it.
+	SelfClosing bool
+}
+
+// Element describes the HTML element a formatting tag is compiled to.
+type Element struct {
+	Name  string
+	Class string
+}
+
+// SemanticElements maps the b, i, u and s tags onto semantic HTML elements.
+var SemanticElements = map[string]Element{
+	"b": {Name: "strong"},
+	"i": {Name: "em"},
+	"u": {Name: "span", Class: "underline"},
+	"s": {Name: "del"},
 }
 
 func NewCompiler(autoCloseTags, ignoreUnmatchedClosingTags bool) Compiler {
 	compiler := Compiler{
 		tagCompilers:               make(map[string]TagCompilerFunc),
+		tagOptions:                 make(map[string]TagOptions),
+		textFilters:                &textFilters{},
+		mediaProviders:             make(map[string]*MediaProvider),
+		mediaTags:                  make(map[string]bool),
 		defaultCompiler:            DefaultTagCompiler,
 		AutoCloseTags:              autoCloseTags,
 		IgnoreUnmatchedClosingTags: ignoreUnmatchedClosingTags,
 		SortOutputAttributes:       false,
+		ClassPrefix:                "bb-",
+		Palette:                    DefaultColorPalette.clone(),
+		SizePolicy:                 DefaultSizePolicy.clone(),
 	}
 
 	for tag, compilerFunc := range DefaultTagCompilers {
 		compiler.SetTag(tag, compilerFunc)
 	}
+	for tag, options := range DefaultTagOptions {
+		compiler.SetTagOptions(tag, options)
+	}
+	compiler.SetTextFilter("autolink", AutoLinkOrder, AutoLinkFilter)
+	compiler.SetTextFilter("mentions", MentionOrder, MentionFilter)
+	compiler.SetTextFilter("smileys", SmileyOrder, SmileyFilter)
+	return compiler
+}
+
+// NewSemanticCompiler is like NewCompiler, but outputs semantic elements such
+// as <strong> and <em> for the formatting tags.
+func NewSemanticCompiler(autoCloseTags, ignoreUnmatchedClosingTags bool) Compiler {
+	compiler := NewCompiler(autoCloseTags, ignoreUnmatchedClosingTags)
+	compiler.Elements = make(map[string]Element, len(SemanticElements))
+	for tag, element := range SemanticElements {
+		compiler.Elements[tag] = element
+	}
 	return compiler
 }
 
 func (c Compiler) Compile(str string) string {
-	tokens := Lex(str)
-	tree := Parse(tokens)
-	return c.CompileTree(tree).Compile(c.SortOutputAttributes)
+	return c.render(c.parse(str))
+}
+
+// Parse lexes and parses str like Compile does. The compiler is kept in the
+// Compiler field of the root, so the extractors skip the contents of its
+// Verbatim tags.
+func (c Compiler) Parse(str string) *BBCodeNode {
+	tree := c.parse(str)
+	tree.Compiler = &c
+	return tree
+}
+
+// parse lexes and parses str, repairing crossed tags if enabled.
+func (c Compiler) parse(str string) *BBCodeNode {
+	if c.RepairCrossedTags {
+		return parseWithRepair(Lex(str), c.tagOptions)
+	}
+	return Parse(Lex(str))
+}
+
+// render compiles tree and serializes it, calling PostCompile in between.
+func (c Compiler) render(tree *BBCodeNode) string {
+	out := c.CompileTree(tree)
+	if c.PostCompile != nil {
+		c.PostCompile(out)
+	}
+	return out.Compile(c.SortOutputAttributes)
 }
 
 func (c Compiler) SetDefault(compiler TagCompilerFunc) {
@@ -57,14 +222,22 @@ func (c Compiler) SetTag(tag string, compiler TagCompilerFunc) {
 	}
 }
 
+func (c Compiler) SetTagOptions(tag string, options TagOptions) {
+	c.tagOptions[tag] = options
+}
+
 // CompileTree transforms BBCodeNode into an HTML tag.
 func (c Compiler) CompileTree(node *BBCodeNode) *HTMLTag {
+	if out, ok := c.cached(node); ok {
+		return out
+	}
 	var out = NewHTMLTag("")
 	if node.ID == TEXT {
-		out.Value = node.Value.(string)
-		InsertNewlines(out)
-		for _, child := range node.Children {
-			out.AppendChild(c.CompileTree(child))
+		if c.Paragraphs && node.Parent == nil {
+			c.compileParagraphs(node, out)
+		} else {
+			out = c.compileText(node, node.Value.(string))
+			c.compileChildren(node, out)
 		}
 	} else if node.ID == CLOSING_TAG {
 		if !c.IgnoreUnmatchedClosingTags {
@@ -74,6 +247,11 @@ func (c Compiler) CompileTree(node *BBCodeNode) *HTMLTag {
 		for _, child := range node.Children {
 			out.AppendChild(c.CompileTree(child))
 		}
+	} else if c.selfClosed(node) {
+		out.AppendChild(c.compileLeaf(node))
+		for _, child := range node.Children {
+			out.AppendChild(c.CompileTree(child))
+		}
 	} else if node.ClosingTag == nil && !c.AutoCloseTags {
 		out.Value = node.Value.(BBOpeningTag).Raw
 		InsertNewlines(out)
@@ -81,28 +259,94 @@ func (c Compiler) CompileTree(node *BBCodeNode) *HTMLTag {
 			out.AppendChild(c.CompileTree(child))
 		}
 	} else {
-		in := node.GetOpeningTag()
+		out = c.compileTag(node)
+	}
+	return c.saveOutput(node, out)
+}
 
-		compileFunc, ok := c.tagCompilers[in.Name]
-		if !ok {
-			compileFunc = c.defaultCompiler
+// compileTag compiles an opening tag with its tag compiler.
+func (c Compiler) compileTag(node *BBCodeNode) *HTMLTag {
+	compileFunc, ok := c.tagCompilers[node.Value.(BBOpeningTag).Name]
+	if !ok {
+		compileFunc = c.defaultCompiler
+	}
+	node = c.bind(node)
+	out, appendExpr := compileFunc(node)
+	if appendExpr {
+		if len(node.Children) == 0 {
+			out.AppendChild(NewHTMLTag(""))
+		} else {
+			c.compileChildren(node, out)
 		}
-		var appendExpr bool
-		node.Compiler = &c
-		out, appendExpr = compileFunc(node)
-		if appendExpr {
-			if len(node.Children) == 0 {
-				out.AppendChild(NewHTMLTag(""))
-			} else {
-				for _, child := range node.Children {
-					out.AppendChild(c.CompileTree(child))
-				}
-			}
+	}
+	return out
+}
+
+// bind returns a copy of node whose Compiler is c, for tag compilers and
+// text filters. The tree itself isn't changed, so it can be compiled by
+// several compilers at once.
+func (c Compiler) bind(node *BBCodeNode) *BBCodeNode {
+	bound := *node
+	bound.Compiler = &c
+	return &bound
+}
+
+// compileLeaf compiles a self-closed tag without the content after it.
+func (c Compiler) compileLeaf(node *BBCodeNode) *HTMLTag {
+	leaf := *node
+	leaf.Children = nil
+	return c.compileTag(&leaf)
+}
+
+// selfClosed reports whether node is an unclosed SelfClosing tag with a
+// value, whose children are the content after it.
+func (c Compiler) selfClosed(node *BBCodeNode) bool {
+	if node.ID != OPENING_TAG || node.ClosingTag != nil {
+		return false
+	}
+	in := node.Value.(BBOpeningTag)
+	return in.Value != "" && c.tagOptions[in.Name].SelfClosing
+}
+
+// compileText compiles the contents of a text node, running it through the
+// text filters unless it is inside a Verbatim tag.
+func (c Compiler) compileText(node *BBCodeNode, text string) *HTMLTag {
+	if !c.hasTextFilters() || c.inVerbatim(node) {
+		return textTag(text)
+	}
+	node = c.bind(node)
+	out := NewHTMLTag("")
+	for _, piece := range c.filterText(node, text) {
+		if isText(piece) {
+			InsertNewlines(piece)
 		}
+		out.AppendChild(piece)
 	}
 	return out
 }
 
+// textTag creates a text tag with newlines converted to line breaks.
+func textTag(text string) *HTMLTag {
+	out := NewHTMLTag(text)
+	InsertNewlines(out)
+	return out
+}
+
+// compileChildren compiles the children of node and appends them to out.
+func (c Compiler) compileChildren(node *BBCodeNode, out *HTMLTag) {
+	if c.Paragraphs && c.isBlock(node) && !noParagraphs[out.Name] {
+		c.compileParagraphs(node, out)
+		return
+	}
+	if c.Paragraphs {
+		c.compileInline(node, out)
+		return
+	}
+	for _, child := range node.Children {
+		out.AppendChild(c.CompileTree(child))
+	}
+}
+
 func CompileText(in *BBCodeNode) string {
 	out := ""
 	if in.ID == TEXT {
@@ -133,8 +377,35 @@ func CompileRaw(in *BBCodeNode) *HTMLTag {
 	return out
 }
 
+// rawText returns the source of the children of node.
+func rawText(node *BBCodeNode) string {
+	var b strings.Builder
+	var write func(n *BBCodeNode)
+	write = func(n *BBCodeNode) {
+		switch value := n.Value.(type) {
+		case string:
+			b.WriteString(value)
+		case BBClosingTag:
+			b.WriteString(value.Raw)
+		case BBOpeningTag:
+			b.WriteString(value.Raw)
+		}
+		for _, child := range n.Children {
+			write(child)
+		}
+		if n.ID == OPENING_TAG && n.ClosingTag != nil {
+			b.WriteString(n.ClosingTag.Raw)
+		}
+	}
+	for _, child := range node.Children {
+		write(child)
+	}
+	return b.String()
+}
+
 var DefaultTagCompilers map[string]TagCompilerFunc
 var DefaultTagCompiler TagCompilerFunc
+var DefaultTagOptions map[string]TagOptions
 
 func init() {
 	DefaultTagCompiler = func(node *BBCodeNode) (*HTMLTag, bool) {
@@ -185,78 +456,167 @@ func init() {
 				out.Attrs["title"] = out.Attrs["alt"]
 			}
 		}
+		if node.Compiler != nil && node.Compiler.ImageProxy != nil {
+			out.Attrs["src"] = node.Compiler.ImageProxy.Rewrite(out.Attrs["src"])
+		}
 		return out, false
 	}
 
 	DefaultTagCompilers["center"] = func(node *BBCodeNode) (*HTMLTag, bool) {
 		out := NewHTMLTag("")
 		out.Name = "div"
-		out.Attrs["style"] = "text-align: center;"
+		if node.Compiler != nil && node.Compiler.ClassOutput {
+			out.Attrs["class"] = node.Compiler.ClassPrefix + "center"
+		} else {
+			out.Attrs["style"] = "text-align: center;"
+		}
 		return out, true
 	}
 
 	DefaultTagCompilers["color"] = func(node *BBCodeNode) (*HTMLTag, bool) {
 		out := NewHTMLTag("")
 		out.Name = "span"
-		sanitize := func(r rune) rune {
-			if r == '#' || r == ',' || r == '.' || r == '(' || r == ')' || r == '%' {
-				return r
-			} else if r >= '0' && r <= '9' {
-				return r
-			} else if r >= 'a' && r <= 'z' {
-				return r
-			} else if r >= 'A' && r <= 'Z' {
-				return r
+		color, ok := ParseColor(node.GetOpeningTag().Value)
+		if !ok {
+			return out, true
+		}
+		if node.Compiler != nil && node.Compiler.ClassOutput {
+			if class, ok := node.Compiler.Palette.Class(color.String()); ok {
+				out.Attrs["class"] = node.Compiler.ClassPrefix + "color-" + class
 			}
-			return -1
+		} else {
+			out.Attrs["style"] = "color: " + color.String() + ";"
 		}
-		color := strings.Map(sanitize, node.GetOpeningTag().Value)
-		out.Attrs["style"] = "color: " + color + ";"
 		return out, true
 	}
 
 	DefaultTagCompilers["size"] = func(node *BBCodeNode) (*HTMLTag, bool) {
 		out := NewHTMLTag("")
 		out.Name = "span"
-		if size, err := strconv.Atoi(node.GetOpeningTag().Value); err == nil {
-			out.Attrs["class"] = fmt.Sprintf("size%d", size)
+		policy := DefaultSizePolicy
+		classOutput, prefix := false, ""
+		if node.Compiler != nil {
+			policy = node.Compiler.SizePolicy
+			classOutput = node.Compiler.ClassOutput
+			prefix = node.Compiler.sizeClassPrefix()
+		}
+		if size, unit, ok := policy.Parse(node.GetOpeningTag().Value); ok {
+			if policy.InlineStyle && !classOutput {
+				if style := policy.Style(size, unit); style != "" {
+					out.Attrs["style"] = style
+				}
+			} else {
+				out.Attrs["class"] = prefix + policy.Class(size, unit)
+			}
 		}
 		return out, true
 	}
 
-	DefaultTagCompilers["quote"] = func(node *BBCodeNode) (*HTMLTag, bool) {
-		out := NewHTMLTag("")
-		out.Name = "blockquote"
-		who := ""
+	DefaultTagCompilers["quote"] = compileQuote
+
+	DefaultTagCompilers["code"] = func(node *BBCodeNode) (*HTMLTag, bool) {
+		var c Compiler
+		if node.Compiler != nil {
+			c = *node.Compiler
+		}
+		code := c.codeText(node)
 		in := node.GetOpeningTag()
-		if name, ok := in.Args["name"]; ok && name != "" {
-			who = name
-		} else {
-			who = in.Value
+		if _, ok := in.Args["lines"]; ok || in.Value != "" || in.Args["highlight"] != "" {
+			return c.codeBlock(node, code), false
 		}
-		cite := NewHTMLTag("")
-		cite.Name = "cite"
-		if who != "" {
-			cite.AppendChild(NewHTMLTag(who + " said:"))
-		} else {
-			cite.AppendChild(NewHTMLTag("Quote"))
+		out := NewHTMLTag("")
+		out.Name = "pre"
+		if strings.HasPrefix(code, "\n") {
+			// HTML parsers drop a newline at the start of a <pre>.
+			code = "\n" + code
 		}
-		return out.AppendChild(cite), true
+		return out.AppendChild(NewHTMLTag(code)), false
 	}
 
-	DefaultTagCompilers["code"] = func(node *BBCodeNode) (*HTMLTag, bool) {
+	DefaultTagCompilers["icode"] = func(node *BBCodeNode) (*HTMLTag, bool) {
+		var c Compiler
+		if node.Compiler != nil {
+			c = *node.Compiler
+		}
 		out := NewHTMLTag("")
-		out.Name = "pre"
-		for _, child := range node.Children {
-			out.AppendChild(CompileRaw(child))
+		out.Name = "code"
+		return out.AppendChild(NewHTMLTag(c.codeText(node))), false
+	}
+	DefaultTagCompilers["c"] = DefaultTagCompilers["icode"]
+
+	DefaultTagCompilers["spoiler"] = func(node *BBCodeNode) (*HTMLTag, bool) {
+		out := NewHTMLTag("")
+		out.Name = "details"
+		in := node.GetOpeningTag()
+		if _, ok := in.Args["open"]; ok {
+			out.Attrs["open"] = ""
 		}
-		return out, false
+		title := in.Value
+		if title == "" && node.Compiler != nil {
+			title = node.Compiler.SpoilerSummary
+		}
+		if title == "" {
+			var c Compiler
+			if node.Compiler != nil {
+				c = *node.Compiler
+			}
+			title = c.Message("spoiler")
+		}
+		summary := NewHTMLTag("")
+		summary.Name = "summary"
+		summary.AppendChild(NewHTMLTag(title))
+		return out.AppendChild(summary), true
+	}
+	DefaultTagCompilers["hide"] = DefaultTagCompilers["spoiler"]
+
+	for _, tag := range []string{"media", "video"} {
+		video := tag == "video"
+		DefaultTagCompilers[tag] = func(node *BBCodeNode) (*HTMLTag, bool) {
+			var c Compiler
+			if node.Compiler != nil {
+				c = *node.Compiler
+			}
+			return c.embedMedia(node, c.allowedMediaProviders(), video), false
+		}
+	}
+
+	DefaultTagCompilers["attach"] = compileAttachment
+
+	// Rows and cells are compiled by the table tag, and are left as they
+	// are when they appear anywhere else.
+	DefaultTagCompilers["table"] = compileTable
+	for _, tag := range []string{"tr", "td", "th"} {
+		DefaultTagCompilers[tag] = DefaultTagCompiler
+	}
+
+	DefaultTagOptions = map[string]TagOptions{
+		"center":  {Block: true},
+		"quote":   {Block: true},
+		"spoiler": {Block: true},
+		"hide":    {Block: true},
+		"table":   {Block: true},
+		"media":   {Verbatim: true},
+		"video":   {Verbatim: true},
+		"attach":  {Verbatim: true, ViewerDependent: true, SelfClosing: true},
+		"code":    {Block: true, Verbatim: true},
+		"icode":   {Verbatim: true},
+		"c":       {Verbatim: true},
+		"url":     {Verbatim: true},
+		"img":     {Verbatim: true},
 	}
 
 	for _, tag := range []string{"i", "b", "u", "s"} {
 		DefaultTagCompilers[tag] = func(node *BBCodeNode) (*HTMLTag, bool) {
 			out := NewHTMLTag("")
 			out.Name = node.GetOpeningTag().Name
+			if node.Compiler != nil {
+				if element, ok := node.Compiler.Elements[out.Name]; ok {
+					out.Name = element.Name
+					if element.Class != "" {
+						out.Attrs["class"] = element.Class
+					}
+				}
+			}
 			return out, true
 		}
 	}
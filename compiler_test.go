@@ -235,3 +235,282 @@ func TestCompileCustom(t *testing.T) {
 		}
 	}
 }
+
+var semanticTests = map[string]string{
+	`[b]bold[/b]`:          `<strong>bold</strong>`,
+	`[i]italic[/i]`:        `<em>italic</em>`,
+	`[u]underline[/u]`:     `<span class="underline">underline</span>`,
+	`[s]strikethrough[/s]`: `<del>strikethrough</del>`,
+	`[b][i]both[/i][/b]`:   `<strong><em>both</em></strong>`,
+}
+
+func TestSemanticElements(t *testing.T) {
+	c := NewSemanticCompiler(false, false)
+	for in, out := range semanticTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+
+	c.Elements["b"] = Element{Name: "span", Class: "bold"}
+	if result := NewSemanticCompiler(false, false).Compile(`[b]b[/b]`); result != `<strong>b</strong>` {
+		t.Errorf("Changing Elements changed the semantic preset: %s\n", result)
+	}
+
+	c.Elements = map[string]Element{"u": {Name: "ins"}}
+	in, out := `[u]a[/u][b]b[/b]`, `<ins>a</ins><b>b</b>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+}
+
+var paragraphTests = map[string]string{
+	``:                                       ``,
+	"hello":                                  `<p>hello</p>`,
+	"a\nb":                                   `<p>a<br>b</p>`,
+	"a\n\nb":                                 `<p>a</p><p>b</p>`,
+	"\n\na\n \n\n\nb\n\n":                    `<p>a</p><p>b</p>`,
+	"a [b]bold[/b]\nc":                       `<p>a <b>bold</b><br>c</p>`,
+	"[b]a\n\nb[/b]":                          `<p><b>a<br><br>b</b></p>`,
+	"[quote]hi[/quote]\nafter":               `<blockquote><cite>Quote</cite><p>hi</p></blockquote><p>after</p>`,
+	"before\n[quote]\nhi\n[/quote]\n":        `<p>before</p><blockquote><cite>Quote</cite><p>hi</p></blockquote>`,
+	"[quote]a\n\nb[/quote]":                  `<blockquote><cite>Quote</cite><p>a</p><p>b</p></blockquote>`,
+	"[code]x\n\ny\n[/code]\n\ntext":          "<pre>x\n\ny\n</pre><p>text</p>",
+	"[center]\nhi\n[/center]":                `<div style="text-align: center;"><p>hi</p></div>`,
+	"[quote]a[/quote] [quote]b[/quote]":      `<blockquote><cite>Quote</cite><p>a</p></blockquote><blockquote><cite>Quote</cite><p>b</p></blockquote>`,
+	"[quote]x\n\ny":                          `<p>[quote]x</p><p>y</p>`,
+	"[unknown]\n\nx[/unknown]":               `<p>[unknown]<br><br>x[/unknown]</p>`,
+	"[b][quote]x[/quote][/b]":                `<b><blockquote><cite>Quote</cite><p>x</p></blockquote></b>`,
+	"a [i]b[quote]x[/quote][/i] c":           `<p>a</p><i>b<blockquote><cite>Quote</cite><p>x</p></blockquote></i><p>c</p>`,
+	"[b][icode][quote]x[/quote][/icode][/b]": `<p><b><code>[quote]x[/quote]</code></b></p>`,
+	"[b]x\n[quote]q[/quote]\ny[/b]":          `<b>x<blockquote><cite>Quote</cite><p>q</p></blockquote>y</b>`,
+	"[b]x\n\n[quote]q[/quote]\n\ny\nz[/b]":   `<b>x<blockquote><cite>Quote</cite><p>q</p></blockquote>y<br>z</b>`,
+}
+
+func TestParagraphs(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.Paragraphs = true
+	for in, out := range paragraphTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
+
+func TestParagraphsCustomBlock(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.Paragraphs = true
+	c.SetTag("list", func(node *BBCodeNode) (*HTMLTag, bool) {
+		out := NewHTMLTag("")
+		out.Name = "ul"
+		return out, true
+	})
+	in := "a\n[list]x[/list]\nb"
+	out := `<p>a<br><ul>x</ul><br>b</p>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+
+	c.SetTagOptions("list", TagOptions{Block: true})
+	out = `<p>a</p><ul>x</ul><p>b</p>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+}
+
+var autoLinkTests = map[string]string{
+	"see https://example.com/x.":                    `see <a href="https://example.com/x">https://example.com/x</a>.`,
+	"(https://en.wikipedia.org/wiki/Foo_(bar))":     `(<a href="https://en.wikipedia.org/wiki/Foo_(bar)">https://en.wikipedia.org/wiki/Foo_(bar)</a>)`,
+	"visit www.example.com, now":                    `visit <a href="http://www.example.com">www.example.com</a>, now`,
+	"mail me@example.com!":                          `mail <a href="mailto:me@example.com">me@example.com</a>!`,
+	"a http://a.com\nb http://b.com":                `a <a href="http://a.com">http://a.com</a><br>b <a href="http://b.com">http://b.com</a>`,
+	"<https://example.com/?a=1&b=2>":                `&lt;<a href="https://example.com/?a=1&amp;b=2">https://example.com/?a=1&amp;b=2</a>&gt;`,
+	"[b]http://example.com[/b]":                     `<b><a href="http://example.com">http://example.com</a></b>`,
+	"[url]http://example.com[/url]":                 `<a href="http://example.com">http://example.com</a>`,
+	"[url=http://a.com]http://b.com[/url]":          `<a href="http://a.com">http://b.com</a>`,
+	"[code]http://example.com[/code]":               `<pre>http://example.com</pre>`,
+	"[img]http://example.com/a.png[/img]":           `<img src="http://example.com/a.png">`,
+	"http:// and www. and foo@bar":                  `http:// and www. and foo@bar`,
+	"javascript:alert(1) ftp://example.com":         `javascript:alert(1) ftp://example.com`,
+	"httpx://example.com":                           `httpx://example.com`,
+	"[url]x[/url] http://example.com":               `<a href="x">x</a> <a href="http://example.com">http://example.com</a>`,
+	"\"http://example.com/a\" 'http://example.com'": `&#34;<a href="http://example.com/a">http://example.com/a</a>&#34; &#39;<a href="http://example.com">http://example.com</a>&#39;`,
+}
+
+func TestAutoLink(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.AutoLink = true
+	for in, out := range autoLinkTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
+
+func TestAutoLinkCustom(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.AutoLink = true
+	c.SetTag("url", func(node *BBCodeNode) (*HTMLTag, bool) {
+		out, appendExpr := DefaultTagCompilers["url"](node)
+		out.Attrs["rel"] = "nofollow"
+		return out, appendExpr
+	})
+	c.SetTag("nolink", func(node *BBCodeNode) (*HTMLTag, bool) {
+		return NewHTMLTag(""), true
+	})
+	c.SetTagOptions("nolink", TagOptions{Verbatim: true})
+	c.SortOutputAttributes = true
+
+	in := "http://a.com [nolink]http://b.com[/nolink]"
+	out := `<a href="http://a.com" rel="nofollow">http://a.com</a> http://b.com`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+
+	c.SetTag("url", nil)
+	in, out = "http://a.com", "http://a.com"
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+}
+
+func TestAutoLinkParagraphs(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.AutoLink = true
+	c.Paragraphs = true
+	in := "http://a.com\n\nb http://b.com"
+	out := `<p><a href="http://a.com">http://a.com</a></p><p>b <a href="http://b.com">http://b.com</a></p>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+}
+
+var repairTests = map[string]string{
+	`[b]bold [i]both[/b] italic[/i]`: `<b>bold <i>both</i></b><i> italic</i>`,
+	`[b][i]x[/b][/i]`:                `<b><i>x</i></b>`,
+	`[b][i][u]a[/b]b[/u]c[/i]`:       `<b><i><u>a</u></i></b><i><u>b</u>c</i>`,
+	`[b][color=red]a[/b]b[/color]`:   `<b><span style="color: red;">a</span></b><span style="color: red;">b</span>`,
+	`[quote][b]x[/quote]y[/b]`:       `<blockquote><cite>Quote</cite><b>x</b></blockquote><b>y</b>`,
+	`[b][quote]x[/b][/quote]`:        `<b>[quote]x</b>[/quote]`,
+	`[b][i]x[/b]y`:                   `<b>[i]x</b>y`,
+	`[b]a[/b][i]b[/i]`:               `<b>a</b><i>b</i>`,
+	`[u][s]a[/u][b]b[/s][/b]`:        `<u><s>a</s></u><s><b>b</b></s>`,
+}
+
+func TestRepairCrossedTags(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.RepairCrossedTags = true
+	for in, out := range repairTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+
+	in := "[b]bold [i]both[/b] italic[/i]"
+	if result := CompileText(ParseWithRepair(Lex(in))); result != "bold both italic" {
+		t.Errorf("Failed to keep text of %s, got: %s\n", in, result)
+	}
+}
+
+var spoilerTests = map[string]string{
+	`[spoiler]text[/spoiler]`:                             `<details><summary>Spoiler</summary>text</details>`,
+	`[spoiler=Ending]text[/spoiler]`:                      `<details><summary>Ending</summary>text</details>`,
+	`[spoiler="<b>x</b>" open]text[/spoiler]`:             `<details open=""><summary>&lt;b&gt;x&lt;/b&gt;</summary>text</details>`,
+	`[hide]text[/hide]`:                                   `<details><summary>Spoiler</summary>text</details>`,
+	`[spoiler][/spoiler]`:                                 `<details><summary>Spoiler</summary></details>`,
+	`[quote=a][spoiler]b[/spoiler][/quote]`:               `<blockquote><cite>a said:</cite><details><summary>Spoiler</summary>b</details></blockquote>`,
+	`[list][li][spoiler=x][b]y[/b][/spoiler][/li][/list]`: `<ul><li><details><summary>x</summary><b>y</b></details></li></ul>`,
+	`[spoiler][spoiler=inner]x[/spoiler][/spoiler]`:       `<details><summary>Spoiler</summary><details><summary>inner</summary>x</details></details>`,
+}
+
+func TestSpoilers(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	for tag, name := range map[string]string{"list": "ul", "li": "li"} {
+		name := name
+		c.SetTag(tag, func(node *BBCodeNode) (*HTMLTag, bool) {
+			out := NewHTMLTag("")
+			out.Name = name
+			return out, true
+		})
+	}
+	for in, out := range spoilerTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+
+	c.SpoilerSummary = "Show"
+	c.Paragraphs = true
+	in := "a\n[hide]b\n\nc[/hide]\nd"
+	out := `<p>a</p><details><summary>Show</summary><p>b</p><p>c</p></details><p>d</p>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+}
+
+var codeWhitespaceTests = map[string]string{
+	"[code]\nx\n[/code]":                      "<pre>\n\nx\n</pre>",
+	"[code]\n\n  x [b]y[/b]\n\n[/code]":       "<pre>\n\n\n  x [b]y[/b]\n\n</pre>",
+	"[code]a\r\nb\rc[/code]":                  "<pre>a\nb\nc</pre>",
+	"[code]\tx\n a\tb\n[url]x\t[/url][/code]": "<pre>    x\n a  b\n[url]x  [/url]</pre>",
+	"[code=go]\n\tx[/code]":                   "<pre><code class=\"language-go\">\n    x</code></pre>",
+	"a [icode]x <b>[b]y[/b]\ty[/icode] b":     "a <code>x &lt;b&gt;[b]y[/b]   y</code> b",
+	"[c]http://a.com :)[/c]":                  "<code>http://a.com :)</code>",
+	"[c][/c]":                                 "<code></code>",
+}
+
+func TestCodeWhitespace(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.CodeTabWidth = 4
+	c.AutoLink = true
+	c.Smileys = map[string]Smiley{":)": {Emoji: "🙂"}}
+	for in, out := range codeWhitespaceTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %q, got: %q\n", in, out, result)
+		}
+	}
+
+	c.CodeTabWidth = 0
+	in, out := "[code]\tx[/code]", "<pre>\tx</pre>"
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %q, got: %q\n", in, out, result)
+	}
+}
+
+var verbatimRepairTests = map[string]string{
+	`[code][b][i]x[/b][/i][/code]`:    `<pre>[b][i]x[/b][/i]</pre>`,
+	`[icode][b][i]x[/b]y[/i][/icode]`: `<code>[b][i]x[/b]y[/i]</code>`,
+	`[b][code][i]x[/b][/i][/code]`:    `<b>[code][i]x</b>[/i][/code]`,
+}
+
+func TestRepairKeepsSource(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.RepairCrossedTags = true
+	for in, out := range verbatimRepairTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+		if raw := CompileRaw(c.parse(in)).Compile(false); raw != in {
+			t.Errorf("Failed to keep source of %s, got: %s\n", in, raw)
+		}
+	}
+
+	// Closing tags of empty reopened copies are moved back to the tags
+	// they continue.
+	for in, out := range map[string]string{
+		`[b][i]x[/b][/i]`:        `[b][i]x[/i][/b]`,
+		`[b][i][u]a[/b][/u][/i]`: `[b][i][u]a[/u][/i][/b]`,
+	} {
+		if raw := CompileRaw(c.parse(in)).Compile(false); raw != out {
+			t.Errorf("Failed to keep source of %s.\nExpected: %s, got: %s\n", in, out, raw)
+		}
+	}
+}
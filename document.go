@@ -0,0 +1,137 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Document is a parsed input that can be rendered many times, such as a
+// post shown to many viewers. Output of subtrees without ViewerDependent
+// tags is kept after the first render and reused.
+type Document struct {
+	compiler Compiler
+	tree     *BBCodeNode
+	cache    *renderCache
+}
+
+// renderCache holds the output of viewer independent subtrees of a
+// Document, for each language of messages. Its maps are guarded by mu, and
+// the saved output is never changed once it is saved.
+type renderCache struct {
+	mu          sync.RWMutex
+	independent map[*BBCodeNode]bool
+	output      map[string]map[*BBCodeNode]*HTMLTag
+}
+
+// Prepare lexes and parses str for rendering with Document.Render. The
+// document keeps a copy of the compiler, but tags and filters set on the
+// compiler later are shared with it.
+func (c Compiler) Prepare(str string) *Document {
+	return &Document{
+		compiler: c,
+		tree:     c.parse(str),
+		cache: &renderCache{
+			independent: make(map[*BBCodeNode]bool),
+			output:      make(map[string]map[*BBCodeNode]*HTMLTag),
+		},
+	}
+}
+
+// Render compiles the document for the viewer described by ctx, which tag
+// compilers read with node.Compiler.Context(). Documents may be rendered
+// concurrently, including for several viewers at once. Tag compilers and
+// text filters are given copies of the nodes of the document, and must not
+// change the tree.
+func (d *Document) Render(ctx context.Context) string {
+	c := d.compiler
+	c.ctx = ctx
+	c.cache = d.cache
+	return c.render(d.tree)
+}
+
+// RenderTo is like Render, but writes the output to w.
+func (d *Document) RenderTo(w io.Writer, ctx context.Context) error {
+	_, err := io.WriteString(w, d.Render(ctx))
+	return err
+}
+
+// Context returns the context of the compile in progress, as set by
+// WithContext or Document.Render. It is never nil.
+func (c Compiler) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// WithContext returns a copy of the compiler that compiles with ctx.
+func (c Compiler) WithContext(ctx context.Context) Compiler {
+	c.ctx = ctx
+	return c
+}
+
+// cached returns the saved output of node, if any.
+func (c Compiler) cached(node *BBCodeNode) (*HTMLTag, bool) {
+	if c.cache == nil {
+		return nil, false
+	}
+	c.cache.mu.RLock()
+	out, ok := c.cache.output[c.messages().Language()][node]
+	c.cache.mu.RUnlock()
+	if ok && c.PostCompile != nil {
+		// PostCompile may change the output in place.
+		out = out.Clone()
+	}
+	return out, ok
+}
+
+// saveOutput keeps the output of node if it is the same for all viewers.
+func (c Compiler) saveOutput(node *BBCodeNode, out *HTMLTag) *HTMLTag {
+	if c.cache == nil || !c.viewerIndependent(node) {
+		return out
+	}
+	lang := c.messages().Language()
+	c.cache.mu.Lock()
+	if c.cache.output[lang] == nil {
+		c.cache.output[lang] = make(map[*BBCodeNode]*HTMLTag)
+	}
+	c.cache.output[lang][node] = out
+	c.cache.mu.Unlock()
+	if c.PostCompile != nil {
+		out = out.Clone()
+	}
+	return out
+}
+
+// viewerDependent reports whether the output of tags named name depends on
+// the viewer. Quotes do when a QuoteResolver is set, as it is given the
+// viewer's context.
+func (c Compiler) viewerDependent(name string) bool {
+	return c.tagOptions[name].ViewerDependent || name == "quote" && c.QuoteResolver != nil
+}
+
+// viewerIndependent reports whether no tag under node, including node
+// itself, is ViewerDependent.
+func (c Compiler) viewerIndependent(node *BBCodeNode) bool {
+	c.cache.mu.RLock()
+	independent, ok := c.cache.independent[node]
+	c.cache.mu.RUnlock()
+	if ok {
+		return independent
+	}
+	independent = node.ID != OPENING_TAG || !c.viewerDependent(node.Value.(BBOpeningTag).Name)
+	for _, child := range node.Children {
+		if !c.viewerIndependent(child) {
+			independent = false
+		}
+	}
+	c.cache.mu.Lock()
+	c.cache.independent[node] = independent
+	c.cache.mu.Unlock()
+	return independent
+}
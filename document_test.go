@@ -0,0 +1,121 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+)
+
+type viewerKey struct{}
+
+func documentCompiler(calls *int) Compiler {
+	c := NewCompiler(false, false)
+	c.SetTag("mod", func(node *BBCodeNode) (*HTMLTag, bool) {
+		out := NewHTMLTag("")
+		if node.Compiler.Context().Value(viewerKey{}) != "mod" {
+			return out, false
+		}
+		out.Name = "div"
+		out.Attrs["class"] = "mod"
+		return out, true
+	})
+	c.SetTagOptions("mod", TagOptions{ViewerDependent: true})
+	c.SetTag("counted", func(node *BBCodeNode) (*HTMLTag, bool) {
+		*calls++
+		out := NewHTMLTag("")
+		out.Name = "span"
+		return out, true
+	})
+	return c
+}
+
+func TestDocumentRender(t *testing.T) {
+	calls := 0
+	c := documentCompiler(&calls)
+	doc := c.Prepare("[counted]a[/counted] [mod]secret [counted]b[/counted][/mod]")
+	mod := context.WithValue(context.Background(), viewerKey{}, "mod")
+
+	tests := []struct {
+		ctx context.Context
+		out string
+	}{
+		{context.Background(), `<span>a</span> `},
+		{mod, `<span>a</span> <div class="mod">secret <span>b</span></div>`},
+		{context.Background(), `<span>a</span> `},
+		{mod, `<span>a</span> <div class="mod">secret <span>b</span></div>`},
+	}
+	for _, test := range tests {
+		if result := doc.Render(test.ctx); result != test.out {
+			t.Errorf("Failed to render document.\nExpected: %s, got: %s\n", test.out, result)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected viewer independent tags to be compiled once each, got %d calls\n", calls)
+	}
+
+	var buf bytes.Buffer
+	if err := doc.RenderTo(&buf, mod); err != nil || buf.String() != tests[1].out {
+		t.Errorf("Failed to render document to writer: %s %v\n", buf.String(), err)
+	}
+	if result := c.WithContext(mod).Compile("[mod]x[/mod]"); result != `<div class="mod">x</div>` {
+		t.Errorf("Failed to compile with context: %s\n", result)
+	}
+}
+
+func TestDocumentPostCompile(t *testing.T) {
+	calls := 0
+	c := documentCompiler(&calls)
+	c.PostCompile = func(root *HTMLTag) {
+		for _, span := range root.FindAll("span") {
+			span.Attrs["class"] += "x"
+		}
+	}
+	doc := c.Prepare("[counted]a[/counted]")
+	for i := 0; i < 3; i++ {
+		if result := doc.Render(context.Background()); result != `<span class="x">a</span>` {
+			t.Errorf("Failed to render document with PostCompile: %s\n", result)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected one compile, got %d\n", calls)
+	}
+}
+
+func TestDocumentConcurrentRender(t *testing.T) {
+	calls := 0
+	c := documentCompiler(&calls)
+	c.AutoLink = true
+	c.Paragraphs = true
+	doc := c.Prepare("http://a.com\n\n[mod]x http://b.com[/mod] [counted]y[/counted]")
+	mod := context.WithValue(context.Background(), viewerKey{}, "mod")
+	expected := map[context.Context]string{
+		context.Background(): doc.Render(context.Background()),
+		mod:                  doc.Render(mod),
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		ctx := context.Background()
+		if i%2 == 1 {
+			ctx = mod
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if result := doc.Render(ctx); result != expected[ctx] {
+				t.Errorf("Failed to render document concurrently.\nExpected: %s, got: %s\n", expected[ctx], result)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("Expected viewer independent tags to be compiled once, got %d calls\n", calls)
+	}
+	if len(doc.cache.output["en"]) > 4 {
+		t.Errorf("Document cache grew to %d entries\n", len(doc.cache.output["en"]))
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+// Link is a link found by ExtractLinks. Pos and End are the byte offsets of
+// the link in the input.
+type Link struct {
+	URL  string
+	Text string
+	// Bare is set for URLs and email addresses written in text rather
+	// than in a [url] tag.
+	Bare bool
+	Pos  int
+	End  int
+}
+
+// Image is an [img] tag found by ExtractImages.
+type Image struct {
+	URL string
+	Alt string
+	Pos int
+	End int
+}
+
+// Quote is a [quote] tag found by ExtractQuotes. Text includes the text of
+// nested quotes, and Depth is the number of quotes around it.
+type Quote struct {
+	Author string
+	Text   string
+	Args   map[string]string
+	Depth  int
+	Pos    int
+	End    int
+}
+
+// MentionRef is an @mention or #hashtag found by ExtractMentions.
+type MentionRef struct {
+	Name    string
+	Hashtag bool
+	Pos     int
+	End     int
+}
+
+// TagUsage is a tag found by ExtractTagUsages.
+type TagUsage struct {
+	Name  string
+	Value string
+	Args  map[string]string
+	Text  string
+	Pos   int
+	End   int
+}
+
+// extract calls fn for each node under n in document order, along with
+// the names of the enclosing tags. The contents of closed Verbatim tags,
+// such as [code], are skipped, so examples inside them are not counted. The
+// tag options are those of the compiler of n, if it was parsed with
+// Compiler.Parse, or else DefaultTagOptions.
+func (n *BBCodeNode) extract(fn func(node *BBCodeNode, parents []string)) {
+	options := DefaultTagOptions
+	if n.Compiler != nil {
+		options = n.Compiler.tagOptions
+	}
+	var walk func(node *BBCodeNode, parents []string)
+	walk = func(node *BBCodeNode, parents []string) {
+		fn(node, parents)
+		if node.ID == OPENING_TAG {
+			name := node.Value.(BBOpeningTag).Name
+			if node.ClosingTag != nil && options[name].Verbatim {
+				return
+			}
+			parents = append(parents, name)
+		}
+		for _, child := range node.Children {
+			walk(child, parents)
+		}
+	}
+	walk(n, nil)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractLinks returns the [url] tags and bare URLs in the tree.
+func (n *BBCodeNode) ExtractLinks() []Link {
+	var links []Link
+	n.extract(func(node *BBCodeNode, parents []string) {
+		switch {
+		case node.ID == TEXT && !contains(parents, "url") && !contains(parents, "img"):
+			text := node.Value.(string)
+			spans, hrefs := findLinks(text)
+			for i, span := range spans {
+				links = append(links, Link{hrefs[i], text[span[0]:span[1]], true, node.Pos + span[0], node.Pos + span[1]})
+			}
+		case node.ID == OPENING_TAG && node.Value.(BBOpeningTag).Name == "url":
+			text := CompileText(node)
+			url := node.Value.(BBOpeningTag).Value
+			if url == "" {
+				url = text
+			}
+			links = append(links, Link{url, text, false, node.Pos, node.End()})
+		}
+	})
+	return links
+}
+
+// ExtractImages returns the [img] tags in the tree.
+func (n *BBCodeNode) ExtractImages() []Image {
+	var images []Image
+	n.extract(func(node *BBCodeNode, parents []string) {
+		if node.ID != OPENING_TAG || node.Value.(BBOpeningTag).Name != "img" {
+			return
+		}
+		image := Image{URL: node.Value.(BBOpeningTag).Value, Pos: node.Pos, End: node.End()}
+		if image.URL == "" {
+			image.URL = CompileText(node)
+		} else {
+			image.Alt = CompileText(node)
+		}
+		images = append(images, image)
+	})
+	return images
+}
+
+// ExtractQuotes returns the [quote] tags in the tree, including nested
+// quotes.
+func (n *BBCodeNode) ExtractQuotes() []Quote {
+	var quotes []Quote
+	n.extract(func(node *BBCodeNode, parents []string) {
+		if node.ID != OPENING_TAG || node.Value.(BBOpeningTag).Name != "quote" {
+			return
+		}
+		tag := node.Value.(BBOpeningTag)
+		author := tag.Args["name"]
+		if author == "" {
+			author = tag.Value
+		}
+		depth := 0
+		for _, name := range parents {
+			if name == "quote" {
+				depth++
+			}
+		}
+		quotes = append(quotes, Quote{author, CompileText(node), tag.Args, depth, node.Pos, node.End()})
+	})
+	return quotes
+}
+
+// ExtractMentions returns the @mention and #hashtag candidates in the text
+// of the tree, outside of [url] tags. They are not resolved.
+func (n *BBCodeNode) ExtractMentions() []MentionRef {
+	var mentions []MentionRef
+	n.extract(func(node *BBCodeNode, parents []string) {
+		if node.ID != TEXT || contains(parents, "url") {
+			return
+		}
+		for _, m := range findMentions(node.Value.(string)) {
+			mentions = append(mentions, MentionRef{m.name, m.hashtag, node.Pos + m.start, node.Pos + m.end})
+		}
+	})
+	return mentions
+}
+
+// ExtractTagUsages returns every use of the named tag in the tree, such as
+// the [attach] tags in a post.
+func (n *BBCodeNode) ExtractTagUsages(name string) []TagUsage {
+	var usages []TagUsage
+	n.extract(func(node *BBCodeNode, parents []string) {
+		if node.ID != OPENING_TAG || node.Value.(BBOpeningTag).Name != name {
+			return
+		}
+		tag := node.Value.(BBOpeningTag)
+		usages = append(usages, TagUsage{tag.Name, tag.Value, tag.Args, CompileText(node), node.Pos, node.End()})
+	})
+	return usages
+}
@@ -0,0 +1,132 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"reflect"
+	"testing"
+)
+
+var extractInput = `see http://a.com/x, [url=http://b.com]B[/url] and [url]http://c.com[/url]
+[quote name=alice post=1]hi @bob [img]http://d.com/i.png[/img]
+[quote=carol]#nested[/quote][/quote]
+[code][url]http://e.com[/url] @dave [quote]x[/quote][/code]
+[img=http://f.com/j.png]alt text[/img] mail eve@example.com [attach]12[/attach][attach=34 type=full][/attach]`
+
+func parseString(str string) *BBCodeNode {
+	return Parse(Lex(str))
+}
+
+func TestExtractLinks(t *testing.T) {
+	expected := []Link{
+		{"http://a.com/x", "http://a.com/x", true, 4, 18},
+		{"http://b.com", "B", false, 20, 45},
+		{"http://c.com", "http://c.com", false, 50, 73},
+		{"mailto:eve@example.com", "eve@example.com", true, 278, 293},
+	}
+	links := parseString(extractInput).ExtractLinks()
+	if !reflect.DeepEqual(links, expected) {
+		t.Errorf("Failed to extract links.\nExpected: %+v, got: %+v\n", expected, links)
+	}
+	for _, link := range links {
+		if link.Bare && extractInput[link.Pos:link.End] != link.Text {
+			t.Errorf("Bad position for %+v: %q\n", link, extractInput[link.Pos:link.End])
+		}
+	}
+}
+
+func TestExtractImages(t *testing.T) {
+	expected := []Image{
+		{"http://d.com/i.png", "", 107, 136},
+		{"http://f.com/j.png", "alt text", 234, 272},
+	}
+	images := parseString(extractInput).ExtractImages()
+	if !reflect.DeepEqual(images, expected) {
+		t.Errorf("Failed to extract images.\nExpected: %+v, got: %+v\n", expected, images)
+	}
+}
+
+func TestExtractQuotes(t *testing.T) {
+	expected := []Quote{
+		{"alice", "hi @bob http://d.com/i.png\n#nested", map[string]string{"name": "alice", "post": "1"}, 0, 74, 173},
+		{"carol", "#nested", map[string]string{}, 1, 137, 165},
+	}
+	quotes := parseString(extractInput).ExtractQuotes()
+	if !reflect.DeepEqual(quotes, expected) {
+		t.Errorf("Failed to extract quotes.\nExpected: %+v, got: %+v\n", expected, quotes)
+	}
+	if raw := extractInput[quotes[1].Pos:quotes[1].End]; raw != "[quote=carol]#nested[/quote]" {
+		t.Errorf("Bad position for nested quote: %q\n", raw)
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	expected := []MentionRef{
+		{"bob", false, 102, 106},
+		{"nested", true, 150, 157},
+	}
+	mentions := parseString(extractInput).ExtractMentions()
+	if !reflect.DeepEqual(mentions, expected) {
+		t.Errorf("Failed to extract mentions.\nExpected: %+v, got: %+v\n", expected, mentions)
+	}
+}
+
+func TestExtractTagUsages(t *testing.T) {
+	expected := []TagUsage{
+		{"attach", "", map[string]string{}, "12", 294, 313},
+		{"attach", "34", map[string]string{"type": "full"}, "", 313, 343},
+	}
+	usages := parseString(extractInput).ExtractTagUsages("attach")
+	if !reflect.DeepEqual(usages, expected) {
+		t.Errorf("Failed to extract tag usages.\nExpected: %+v, got: %+v\n", expected, usages)
+	}
+	if code := parseString(extractInput).ExtractTagUsages("code"); len(code) != 1 || code[0].Text != "http://e.com @dave x" {
+		t.Errorf("Failed to extract code tag: %+v\n", code)
+	}
+}
+
+func TestExtractSkipsVerbatim(t *testing.T) {
+	tree := parseString("[icode]@a http://a.com [quote]x[/quote][/icode] [c]#b[/c] [b]@c[/b]")
+	if mentions := tree.ExtractMentions(); len(mentions) != 1 || mentions[0].Name != "c" {
+		t.Errorf("Failed to skip inline code for mentions: %+v\n", mentions)
+	}
+	if links := tree.ExtractLinks(); len(links) != 0 {
+		t.Errorf("Failed to skip inline code for links: %+v\n", links)
+	}
+	if quotes := tree.ExtractQuotes(); len(quotes) != 0 {
+		t.Errorf("Failed to skip inline code for quotes: %+v\n", quotes)
+	}
+}
+
+func TestExtractAfterUnclosedVerbatim(t *testing.T) {
+	tree := parseString("[attach=1] see http://a.com [url]http://b.com[/url] @bob [quote=x]hi[/quote] [code]@hidden")
+	if links := tree.ExtractLinks(); len(links) != 2 {
+		t.Errorf("Failed to extract links after unclosed tags: %+v\n", links)
+	}
+	if mentions := tree.ExtractMentions(); len(mentions) != 2 || mentions[0].Name != "bob" || mentions[1].Name != "hidden" {
+		t.Errorf("Failed to extract mentions after unclosed tags: %+v\n", mentions)
+	}
+	if quotes := tree.ExtractQuotes(); len(quotes) != 1 || quotes[0].Author != "x" {
+		t.Errorf("Failed to extract quotes after unclosed tags: %+v\n", quotes)
+	}
+}
+
+func TestExtractCompilerTagOptions(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SetTag("noparse", func(node *BBCodeNode) (*HTMLTag, bool) {
+		return NewHTMLTag(rawText(node)), false
+	})
+	c.SetTagOptions("noparse", TagOptions{Verbatim: true})
+	in := "[noparse]@a [quote]x[/quote][/noparse] @b"
+	if mentions := c.Parse(in).ExtractMentions(); len(mentions) != 1 || mentions[0].Name != "b" {
+		t.Errorf("Failed to skip custom verbatim tag: %+v\n", mentions)
+	}
+	if mentions := parseString(in).ExtractMentions(); len(mentions) != 2 {
+		t.Errorf("Skipped tag that isn't verbatim by default: %+v\n", mentions)
+	}
+	if result := c.Compile(in); result != "@a [quote]x[/quote] @b" {
+		t.Errorf("Failed to compile custom verbatim tag: %s\n", result)
+	}
+}
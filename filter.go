@@ -0,0 +1,170 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import "sort"
+
+// TextFilter rewrites text into a sequence of text and HTML tags. Filters
+// run in order on the text of each TEXT node outside of Verbatim tags, and
+// each filter only sees the plain text tags left by the filters before it.
+type TextFilter interface {
+	Filter(text string) []*HTMLTag
+}
+
+// TextFilterFunc is an adapter to allow the use of ordinary functions as
+// text filters.
+type TextFilterFunc func(text string) []*HTMLTag
+
+func (f TextFilterFunc) Filter(text string) []*HTMLTag {
+	return f(text)
+}
+
+// NodeTextFilter is implemented by text filters that need the node being
+// compiled. When a filter implements it, FilterNode is called instead of
+// Filter, and node.Compiler is set to the compiler in use.
+type NodeTextFilter interface {
+	TextFilter
+	FilterNode(node *BBCodeNode, text string) []*HTMLTag
+}
+
+// Orders of the built-in text filters registered by NewCompiler.
+const (
+	AutoLinkOrder = 100
+	MentionOrder  = 150
+	SmileyOrder   = 200
+)
+
+// AutoLinkFilter links bare URLs and email addresses when the compiler has
+// AutoLink enabled.
+var AutoLinkFilter NodeTextFilter = autoLinkFilter{}
+
+// SmileyFilter replaces the compiler's Smileys.
+var SmileyFilter NodeTextFilter = smileyFilter{}
+
+// optionalFilter is implemented by the built-in filters, which do nothing
+// unless enabled on the compiler.
+type optionalFilter interface {
+	enabled(c *Compiler) bool
+}
+
+type autoLinkFilter struct{}
+
+func (autoLinkFilter) enabled(c *Compiler) bool {
+	_, ok := c.tagCompilers["url"]
+	return ok && c.AutoLink
+}
+
+func (autoLinkFilter) Filter(text string) []*HTMLTag {
+	return []*HTMLTag{NewHTMLTag(text)}
+}
+
+func (f autoLinkFilter) FilterNode(node *BBCodeNode, text string) []*HTMLTag {
+	if !f.enabled(node.Compiler) {
+		return []*HTMLTag{NewHTMLTag(text)}
+	}
+	return node.Compiler.autoLink(node, text)
+}
+
+type smileyFilter struct{}
+
+func (smileyFilter) enabled(c *Compiler) bool {
+	return len(c.Smileys) > 0
+}
+
+func (smileyFilter) Filter(text string) []*HTMLTag {
+	return []*HTMLTag{NewHTMLTag(text)}
+}
+
+func (f smileyFilter) FilterNode(node *BBCodeNode, text string) []*HTMLTag {
+	if !f.enabled(node.Compiler) {
+		return []*HTMLTag{NewHTMLTag(text)}
+	}
+	return node.Compiler.replaceSmileys(text)
+}
+
+type textFilter struct {
+	name   string
+	order  int
+	filter TextFilter
+}
+
+// textFilters holds the text filters of a compiler, sorted by order.
+type textFilters struct {
+	sorted []textFilter
+}
+
+func (f *textFilters) set(name string, order int, filter TextFilter) {
+	for i, entry := range f.sorted {
+		if entry.name == name {
+			f.sorted = append(f.sorted[:i:i], f.sorted[i+1:]...)
+			break
+		}
+	}
+	if filter != nil {
+		f.sorted = append(f.sorted, textFilter{name, order, filter})
+	}
+	sort.SliceStable(f.sorted, func(i, j int) bool {
+		if f.sorted[i].order != f.sorted[j].order {
+			return f.sorted[i].order < f.sorted[j].order
+		}
+		return f.sorted[i].name < f.sorted[j].name
+	})
+}
+
+// SetTextFilter registers a text filter under name. Filters run in
+// ascending order, and setting a nil filter removes it.
+func (c Compiler) SetTextFilter(name string, order int, filter TextFilter) {
+	c.textFilters.set(name, order, filter)
+}
+
+// hasTextFilters reports whether any of the compiler's text filters would
+// change text.
+func (c *Compiler) hasTextFilters() bool {
+	if c.textFilters == nil {
+		return false
+	}
+	for _, entry := range c.textFilters.sorted {
+		if optional, ok := entry.filter.(optionalFilter); !ok || optional.enabled(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterText runs the text filters of the compiler on text.
+func (c Compiler) filterText(node *BBCodeNode, text string) []*HTMLTag {
+	pieces := []*HTMLTag{NewHTMLTag(text)}
+	for _, entry := range c.textFilters.sorted {
+		if optional, ok := entry.filter.(optionalFilter); ok && !optional.enabled(&c) {
+			continue
+		}
+		filter := entry.filter.Filter
+		if nodeFilter, ok := entry.filter.(NodeTextFilter); ok {
+			filter = func(text string) []*HTMLTag {
+				return nodeFilter.FilterNode(node, text)
+			}
+		}
+		pieces = mapText(pieces, filter)
+	}
+	return pieces
+}
+
+// mapText replaces each plain text tag in pieces with the result of fn.
+func mapText(pieces []*HTMLTag, fn func(string) []*HTMLTag) []*HTMLTag {
+	var out []*HTMLTag
+	for _, piece := range pieces {
+		if isText(piece) {
+			out = append(out, fn(piece.Value)...)
+		} else {
+			out = append(out, piece)
+		}
+	}
+	return out
+}
+
+// isText reports whether t is a plain text tag.
+func isText(t *HTMLTag) bool {
+	return t.Name == "" && len(t.Children) == 0
+}
@@ -0,0 +1,85 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"strings"
+	"testing"
+)
+
+func censor(text string) []*HTMLTag {
+	var out []*HTMLTag
+	for i, part := range strings.Split(text, "darn") {
+		if i > 0 {
+			tag := NewHTMLTag("")
+			tag.Name = "span"
+			tag.Attrs["class"] = "censored"
+			tag.AppendChild(NewHTMLTag("****"))
+			out = append(out, tag)
+		}
+		if part != "" {
+			out = append(out, NewHTMLTag(part))
+		}
+	}
+	return out
+}
+
+func upper(text string) []*HTMLTag {
+	return []*HTMLTag{NewHTMLTag(strings.ToUpper(text))}
+}
+
+var textFilterTests = map[string]string{
+	"darn it":                            `<span class="censored">****</span> IT`,
+	"a\ndarn\nb":                         `A<br><span class="censored">****</span><br>B`,
+	"[b]darn[/b]":                        `<b><span class="censored">****</span></b>`,
+	"[code]darn[/code]":                  `<pre>darn</pre>`,
+	"[url=http://example.com]darn[/url]": `<a href="http://example.com">darn</a>`,
+	"[quote=darn]darn[/quote]":           `<blockquote><cite>darn said:</cite><span class="censored">****</span></blockquote>`,
+}
+
+func TestTextFilters(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SetTextFilter("upper", 20, TextFilterFunc(upper))
+	c.SetTextFilter("censor", 10, TextFilterFunc(censor))
+	for in, out := range textFilterTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+
+	c.SetTextFilter("upper", 20, nil)
+	in, out := "darn it", `<span class="censored">****</span> it`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+
+	c.SetTextFilter("censor", 10, nil)
+	c.SetTag("nofilter", func(node *BBCodeNode) (*HTMLTag, bool) {
+		return NewHTMLTag(""), true
+	})
+	c.SetTagOptions("nofilter", TagOptions{Verbatim: true})
+	c.SetTextFilter("upper", 0, TextFilterFunc(upper))
+	in, out = "a [nofilter]b[/nofilter]", `A b`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+}
+
+func TestTextFilterOrder(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.AutoLink = true
+	c.SetTextFilter("upper", 50, TextFilterFunc(upper))
+	in, out := "see http://example.com/a", `SEE <a href="http://EXAMPLE.COM/A">HTTP://EXAMPLE.COM/A</a>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+
+	c.SetTextFilter("autolink", 10, AutoLinkFilter)
+	out = `SEE <a href="http://example.com/a">http://example.com/a</a>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Span is a piece of highlighted code. Spans with a Class are output as
+// <span class="hl-Class">, and spans without one as plain text.
+type Span struct {
+	Class string
+	Text  string
+}
+
+// Classes of the spans returned by the built-in highlighter.
+const (
+	HighlightComment  = "comment"
+	HighlightKeyword  = "keyword"
+	HighlightNumber   = "number"
+	HighlightProperty = "property"
+	HighlightString   = "string"
+	HighlightVariable = "variable"
+)
+
+// Highlighter splits code written in a language into highlighted spans.
+// The text of the spans must add up to the code. Highlighters return false
+// for languages they don't support, which are output as plain text.
+type Highlighter interface {
+	Highlight(lang, code string) ([]Span, bool)
+}
+
+// HighlighterFunc is an adapter to allow the use of ordinary functions as
+// highlighters.
+type HighlighterFunc func(lang, code string) ([]Span, bool)
+
+func (f HighlighterFunc) Highlight(lang, code string) ([]Span, bool) {
+	return f(lang, code)
+}
+
+// BuiltinHighlighter highlights Go, JSON and shell scripts.
+var BuiltinHighlighter Highlighter = HighlighterFunc(func(lang, code string) ([]Span, bool) {
+	rules, ok := highlightLanguages[lang]
+	if !ok {
+		return nil, false
+	}
+	return rules.highlight(code), true
+})
+
+// highlightRules describes the syntax of a language for the built-in
+// highlighter.
+type highlightRules struct {
+	keywords     map[string]bool
+	lineComment  string
+	blockComment [2]string
+	quotes       string // Quotes that start strings with backslash escapes.
+	rawQuotes    string // Quotes that start strings without escapes.
+	multiline    bool   // Whether strings with escapes may span lines.
+	variables    bool   // Whether $name is a variable.
+	properties   bool   // Whether strings followed by a colon are properties.
+}
+
+func keywordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+var goRules = &highlightRules{
+	keywords: keywordSet(`break case chan const continue default defer else fallthrough for func go goto if
+		import interface map package range return select struct switch type var true false nil iota`),
+	lineComment:  "//",
+	blockComment: [2]string{"/*", "*/"},
+	quotes:       `"'`,
+	rawQuotes:    "`",
+}
+
+var jsonRules = &highlightRules{
+	keywords:   keywordSet("true false null"),
+	quotes:     `"`,
+	properties: true,
+}
+
+var shellRules = &highlightRules{
+	keywords: keywordSet(`if then else elif fi for while until do done case esac in function select
+		return break continue local export readonly`),
+	lineComment: "#",
+	quotes:      `"`,
+	rawQuotes:   "'",
+	multiline:   true,
+	variables:   true,
+}
+
+var highlightLanguages = map[string]*highlightRules{
+	"go":     goRules,
+	"golang": goRules,
+	"json":   jsonRules,
+	"sh":     shellRules,
+	"shell":  shellRules,
+	"bash":   shellRules,
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+func (r *highlightRules) highlight(code string) []Span {
+	var spans []Span
+	add := func(class, text string) {
+		if class == "" && len(spans) > 0 && spans[len(spans)-1].Class == "" {
+			spans[len(spans)-1].Text += text
+		} else {
+			spans = append(spans, Span{class, text})
+		}
+	}
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		c := code[i]
+		afterWord := i > 0 && isWordByte(code[i-1])
+		end := i + 1
+		class := ""
+		switch {
+		case r.lineComment != "" && strings.HasPrefix(rest, r.lineComment) && (r.lineComment != "#" || i == 0 || unicode.IsSpace(rune(code[i-1]))):
+			end = i + len(rest)
+			if j := strings.IndexByte(rest, '\n'); j >= 0 {
+				end = i + j
+			}
+			class = HighlightComment
+		case r.blockComment[0] != "" && strings.HasPrefix(rest, r.blockComment[0]):
+			end = len(code)
+			if j := strings.Index(rest[len(r.blockComment[0]):], r.blockComment[1]); j >= 0 {
+				end = i + len(r.blockComment[0]) + j + len(r.blockComment[1])
+			}
+			class = HighlightComment
+		case strings.IndexByte(r.quotes, c) >= 0 || strings.IndexByte(r.rawQuotes, c) >= 0:
+			end = r.stringEnd(code, i)
+			class = HighlightString
+			if r.properties && strings.HasPrefix(strings.TrimLeft(code[end:], " \t\r\n"), ":") {
+				class = HighlightProperty
+			}
+		case r.variables && c == '$' && len(rest) > 1:
+			switch {
+			case rest[1] == '{':
+				end = len(code)
+				if j := strings.IndexByte(rest, '}'); j >= 0 {
+					end = i + j + 1
+				}
+			case isWordByte(rest[1]):
+				for end = i + 1; end < len(code) && isWordByte(code[end]); end++ {
+				}
+			case strings.IndexByte("?!#@*$-", rest[1]) >= 0:
+				end = i + 2
+			}
+			if end > i+1 {
+				class = HighlightVariable
+			}
+		case c >= '0' && c <= '9' && !afterWord:
+			for end = i + 1; end < len(code) && (isWordByte(code[end]) || code[end] == '.'); end++ {
+			}
+			class = HighlightNumber
+		case isWordByte(c):
+			for end = i + 1; end < len(code) && isWordByte(code[end]); end++ {
+			}
+			if !afterWord && r.keywords[code[i:end]] {
+				class = HighlightKeyword
+			}
+		}
+		add(class, code[i:end])
+		i = end
+	}
+	return spans
+}
+
+// stringEnd returns the end of the string starting with the quote at i.
+// Unterminated strings end at the end of the line, or of the code if they
+// may span lines.
+func (r *highlightRules) stringEnd(code string, i int) int {
+	quote := code[i]
+	raw := strings.IndexByte(r.rawQuotes, quote) >= 0
+	for j := i + 1; j < len(code); j++ {
+		switch {
+		case code[j] == quote:
+			return j + 1
+		case code[j] == '\\' && !raw:
+			j++
+		case code[j] == '\n' && !raw && !r.multiline:
+			return j
+		}
+	}
+	return len(code)
+}
+
+// codeLanguage returns the language of a [code=lang] tag, lowercased and
+// limited to characters that are safe in a class name.
+func codeLanguage(value string) string {
+	return strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || strings.ContainsRune("+#-_.", r) {
+			return r
+		}
+		return -1
+	}, value)
+}
+
+// lineRanges is a list of inclusive ranges of line numbers.
+type lineRanges [][2]int
+
+func (r lineRanges) contains(line int) bool {
+	for _, bounds := range r {
+		if line >= bounds[0] && line <= bounds[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLineRanges parses a list of line numbers and ranges, such as 2-4,7.
+func parseLineRanges(value string) lineRanges {
+	var lines lineRanges
+	for _, part := range strings.Split(value, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		first, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			continue
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil {
+				continue
+			}
+		}
+		lines = append(lines, [2]int{first, last})
+	}
+	return lines
+}
+
+// codeText returns the contents of a code tag as written, with line
+// endings normalized to \n and tabs expanded to CodeTabWidth.
+func (c Compiler) codeText(node *BBCodeNode) string {
+	code := strings.Replace(rawText(node), "\r\n", "\n", -1)
+	code = strings.Replace(code, "\r", "\n", -1)
+	if c.CodeTabWidth <= 0 || !strings.Contains(code, "\t") {
+		return code
+	}
+	var b strings.Builder
+	column := 0
+	for _, r := range code {
+		switch r {
+		case '\t':
+			spaces := c.CodeTabWidth - column%c.CodeTabWidth
+			b.WriteString(strings.Repeat(" ", spaces))
+			column += spaces
+		case '\n':
+			b.WriteRune(r)
+			column = 0
+		default:
+			b.WriteRune(r)
+			column++
+		}
+	}
+	return b.String()
+}
+
+// codeBlock compiles a [code] tag with a language or line options into
+// <pre><code>.
+func (c Compiler) codeBlock(node *BBCodeNode, code string) *HTMLTag {
+	in := node.GetOpeningTag()
+	lang := codeLanguage(in.Value)
+
+	out := NewHTMLTag("")
+	out.Name = "pre"
+	inner := NewHTMLTag("")
+	inner.Name = "code"
+	if lang != "" {
+		inner.Attrs["class"] = "language-" + lang
+	}
+	out.AppendChild(inner)
+
+	spans := []Span{{Text: code}}
+	if c.Highlighter != nil && lang != "" {
+		if highlighted, ok := c.Highlighter.Highlight(lang, code); ok {
+			spans = highlighted
+		}
+	}
+
+	numbered, hasLines := in.Args["lines"]
+	ranges := in.Args["highlight"]
+	if !hasLines && ranges == "" {
+		for _, span := range spans {
+			inner.AppendChild(spanTag(span))
+		}
+		if len(inner.Children) == 0 {
+			inner.AppendChild(NewHTMLTag(""))
+		}
+		return out
+	}
+
+	start := 1
+	if n, err := strconv.Atoi(numbered); err == nil && n >= 0 {
+		start = n
+	}
+	highlighted := parseLineRanges(ranges)
+	if strings.HasPrefix(code, "\n") {
+		// The newline after the opening tag doesn't start a line.
+		inner.AppendChild(NewHTMLTag("\n"))
+		spans = trimSpans(spans, 1)
+	}
+	lines := [][]Span{nil}
+	for _, span := range spans {
+		for i, part := range strings.Split(span.Text, "\n") {
+			if i > 0 {
+				lines = append(lines, nil)
+			}
+			if part != "" {
+				lines[len(lines)-1] = append(lines[len(lines)-1], Span{span.Class, part})
+			}
+		}
+	}
+	// A newline before the closing tag doesn't start a line.
+	trailing := len(lines) > 1 && len(lines[len(lines)-1]) == 0
+	if trailing {
+		lines = lines[:len(lines)-1]
+	}
+	for i, parts := range lines {
+		if i > 0 {
+			inner.AppendChild(NewHTMLTag("\n"))
+		}
+		line := NewHTMLTag("")
+		line.Name = "span"
+		line.Attrs["class"] = "line"
+		if highlighted.contains(start + i) {
+			line.Attrs["class"] = "line highlight"
+		}
+		if hasLines {
+			line.Attrs["data-line"] = strconv.Itoa(start + i)
+		}
+		for _, part := range parts {
+			line.AppendChild(spanTag(part))
+		}
+		if len(parts) == 0 {
+			line.AppendChild(NewHTMLTag(""))
+		}
+		inner.AppendChild(line)
+	}
+	if trailing {
+		inner.AppendChild(NewHTMLTag("\n"))
+	}
+	return out
+}
+
+// trimSpans removes the first n bytes of text from spans.
+func trimSpans(spans []Span, n int) []Span {
+	for len(spans) > 0 && n > 0 {
+		if len(spans[0].Text) > n {
+			spans[0].Text = spans[0].Text[n:]
+			break
+		}
+		n -= len(spans[0].Text)
+		spans = spans[1:]
+	}
+	return spans
+}
+
+func spanTag(span Span) *HTMLTag {
+	if span.Class == "" {
+		return NewHTMLTag(span.Text)
+	}
+	out := NewHTMLTag("")
+	out.Name = "span"
+	out.Attrs["class"] = "hl-" + span.Class
+	out.AppendChild(NewHTMLTag(span.Text))
+	return out
+}
@@ -0,0 +1,119 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+var highlightTests = map[string]string{
+	"[code]x := 1[/code]":             `<pre>x := 1</pre>`,
+	"[code=Go]x := 1[/code]":          `<pre><code class="language-go">x := <span class="hl-number">1</span></code></pre>`,
+	`[code="c<b>"]a<b[/code]`:         `<pre><code class="language-cb">a&lt;b</code></pre>`,
+	"[code=ruby]def x; end[/code]":    `<pre><code class="language-ruby">def x; end</code></pre>`,
+	"[code=go][/code]":                `<pre><code class="language-go"></code></pre>`,
+	"[code=go][b]x[/b] // [i][/code]": `<pre><code class="language-go">[b]x[/b] <span class="hl-comment">// [i]</span></code></pre>`,
+}
+
+func TestCodeLanguage(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	c.Highlighter = BuiltinHighlighter
+	for in, out := range highlightTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+
+	c.Highlighter = nil
+	in := "[code=go]x := 1[/code]"
+	out := `<pre><code class="language-go">x := 1</code></pre>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+
+	c.Highlighter = HighlighterFunc(func(lang, code string) ([]Span, bool) {
+		return []Span{{"custom-" + lang, code}}, true
+	})
+	in = "[code=x]y[/code]"
+	out = `<pre><code class="language-x"><span class="hl-custom-x">y</span></code></pre>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+}
+
+var builtinHighlightTests = map[string][]Span{
+	"go:func f() { return \"a\\\"b\" } // c": {
+		{"keyword", "func"}, {"", " f() { "}, {"keyword", "return"}, {"", " "},
+		{"string", `"a\"b"`}, {"", " } "}, {"comment", "// c"},
+	},
+	"go:/* a\nb */ x2 := `raw\n` + 0x1F": {
+		{"comment", "/* a\nb */"}, {"", " x2 := "}, {"string", "`raw\n`"}, {"", " + "}, {"number", "0x1F"},
+	},
+	`json:{"a": [1.5, true, null, "b"]}`: {
+		{"", "{"}, {"property", `"a"`}, {"", ": ["}, {"number", "1.5"}, {"", ", "}, {"keyword", "true"},
+		{"", ", "}, {"keyword", "null"}, {"", ", "}, {"string", `"b"`}, {"", "]}"},
+	},
+	"sh:if [ -n \"$HOME\" ]; then echo 'x#y' ${PATH} $?; fi # done": {
+		{"keyword", "if"}, {"", " [ -n "}, {"string", `"$HOME"`}, {"", " ]; "}, {"keyword", "then"},
+		{"", " echo "}, {"string", "'x#y'"}, {"", " "}, {"variable", "${PATH}"}, {"", " "},
+		{"variable", "$?"}, {"", "; "}, {"keyword", "fi"}, {"", " "}, {"comment", "# done"},
+	},
+}
+
+func TestBuiltinHighlighter(t *testing.T) {
+	for in, expected := range builtinHighlightTests {
+		parts := strings.SplitN(in, ":", 2)
+		spans, ok := BuiltinHighlighter.Highlight(parts[0], parts[1])
+		if !ok || !reflect.DeepEqual(spans, expected) {
+			t.Errorf("Failed to highlight %s.\nExpected: %v, got: %v\n", in, expected, spans)
+		}
+	}
+	if _, ok := BuiltinHighlighter.Highlight("cobol", "x"); ok {
+		t.Error("Highlighted an unsupported language")
+	}
+
+	for _, lang := range []string{"go", "json", "sh"} {
+		keepsText := func(code string) bool {
+			spans, _ := BuiltinHighlighter.Highlight(lang, code)
+			text := ""
+			for _, span := range spans {
+				text += span.Text
+			}
+			return text == code
+		}
+		if err := quick.Check(keepsText, nil); err != nil {
+			t.Errorf("Highlighting %s changed the code: %s\n", lang, err)
+		}
+	}
+}
+
+var codeLineTests = map[string]string{
+	"[code lines]a\n\nb[/code]": `<pre><code><span class="line" data-line="1">a</span>` + "\n" +
+		`<span class="line" data-line="2"></span>` + "\n" + `<span class="line" data-line="3">b</span></code></pre>`,
+	"[code=go lines=10 highlight=11]\nx\n/* y\nz */\n[/code]": `<pre><code class="language-go">` + "\n" +
+		`<span class="line" data-line="10">x</span>` + "\n" +
+		`<span class="line highlight" data-line="11"><span class="hl-comment">/* y</span></span>` + "\n" +
+		`<span class="line" data-line="12"><span class="hl-comment">z */</span></span>` + "\n" + `</code></pre>`,
+	"[code highlight=2-3,x,5]a\nb\nc\nd\ne[/code]": `<pre><code><span class="line">a</span>` + "\n" +
+		`<span class="line highlight">b</span>` + "\n" + `<span class="line highlight">c</span>` + "\n" +
+		`<span class="line">d</span>` + "\n" + `<span class="line highlight">e</span></code></pre>`,
+}
+
+func TestCodeLines(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	c.Highlighter = BuiltinHighlighter
+	for in, out := range codeLineTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
@@ -83,6 +83,81 @@ func (t *HTMLTag) AppendChild(child *HTMLTag) *HTMLTag {
 	return t
 }
 
+// Clone returns a deep copy of t.
+func (t *HTMLTag) Clone() *HTMLTag {
+	out := &HTMLTag{
+		Name:     t.Name,
+		Value:    t.Value,
+		Attrs:    make(map[string]string, len(t.Attrs)),
+		Children: make([]*HTMLTag, len(t.Children)),
+	}
+	for key, value := range t.Attrs {
+		out.Attrs[key] = value
+	}
+	for i, child := range t.Children {
+		out.Children[i] = child.Clone()
+	}
+	return out
+}
+
+// Walk calls fn for t and each tag under it in depth-first order. The
+// children of a tag are skipped when fn returns false. Children are read
+// before fn is called, so fn may replace or wrap the tag it is given, and
+// the tags it adds are not visited.
+func (t *HTMLTag) Walk(fn func(tag *HTMLTag) bool) {
+	children := append([]*HTMLTag(nil), t.Children...)
+	if !fn(t) {
+		return
+	}
+	for _, child := range children {
+		child.Walk(fn)
+	}
+}
+
+// FindAll returns the elements under t, including t itself, with the given
+// name, in document order.
+func (t *HTMLTag) FindAll(name string) []*HTMLTag {
+	var found []*HTMLTag
+	t.Walk(func(tag *HTMLTag) bool {
+		if tag.Name == name {
+			found = append(found, tag)
+		}
+		return true
+	})
+	return found
+}
+
+// ReplaceWith replaces t in place with the given tags. Tags don't know
+// their parent, so t itself is changed into a nameless tag holding the
+// replacements. If t is one of the replacements or is inside one, such as
+// when wrapping t in a link, a copy of it is used.
+func (t *HTMLTag) ReplaceWith(tags ...*HTMLTag) {
+	original := *t
+	swap := func(tag *HTMLTag) *HTMLTag {
+		if tag == t {
+			return &original
+		}
+		return tag
+	}
+	children := make([]*HTMLTag, len(tags))
+	for i, tag := range tags {
+		children[i] = swap(tag)
+		children[i].Walk(func(parent *HTMLTag) bool {
+			for j, child := range parent.Children {
+				parent.Children[j] = swap(child)
+			}
+			return parent != &original
+		})
+	}
+	*t = HTMLTag{Attrs: make(map[string]string), Children: children}
+}
+
+// Remove removes t and its children from the tree it is in, by replacing it
+// with nothing.
+func (t *HTMLTag) Remove() {
+	t.ReplaceWith()
+}
+
 func InsertNewlines(out *HTMLTag) {
 	if strings.ContainsRune(out.Value, '\n') {
 		parts := strings.Split(out.Value, "\n")
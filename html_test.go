@@ -22,3 +22,90 @@ func TestValidURL(t *testing.T) {
 		}
 	}
 }
+
+func TestHTMLTagQuery(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	out := c.CompileTree(Parse(Lex("[img]a.png[/img][b][img]b.png[/img][/b][url=http://example.com]x[/url]")))
+	images := out.FindAll("img")
+	if len(images) != 2 || images[1].Attrs["src"] != "b.png" {
+		t.Errorf("Failed to find images: %v\n", images)
+	}
+	if links := out.FindAll("a"); len(links) != 1 {
+		t.Errorf("Failed to find links: %v\n", links)
+	}
+
+	images[0].Remove()
+	link := out.FindAll("a")[0]
+	em := NewHTMLTag("")
+	em.Name = "em"
+	em.AppendChild(NewHTMLTag("gone"))
+	link.ReplaceWith(NewHTMLTag("see "), link)
+	out.FindAll("b")[0].ReplaceWith(em)
+	expected := `<em>gone</em>see <a href="http://example.com">x</a>`
+	if result := out.Compile(true); result != expected {
+		t.Errorf("Failed to modify tree.\nExpected: %s, got: %s\n", expected, result)
+	}
+	if images := out.FindAll("img"); len(images) != 0 {
+		t.Errorf("Removed images still found: %v\n", images)
+	}
+}
+
+func TestHTMLTagWrap(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	out := c.CompileTree(Parse(Lex("[img]a.png[/img] [b][img]b.png[/img][/b]")))
+	for _, img := range out.FindAll("img") {
+		link := NewHTMLTag("")
+		link.Name = "a"
+		link.Attrs["href"] = img.Attrs["src"]
+		figure := NewHTMLTag("")
+		figure.Name = "figure"
+		img.ReplaceWith(figure.AppendChild(link.AppendChild(img)))
+	}
+	expected := `<figure><a href="a.png"><img src="a.png"></a></figure> <b><figure><a href="b.png"><img src="b.png"></a></figure></b>`
+	if result := out.Compile(true); result != expected {
+		t.Errorf("Failed to wrap tags.\nExpected: %s, got: %s\n", expected, result)
+	}
+	if images := out.FindAll("img"); len(images) != 2 {
+		t.Errorf("Failed to find wrapped images: %v\n", images)
+	}
+}
+
+func TestHTMLTagWrapDuringWalk(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	c.PostCompile = func(root *HTMLTag) {
+		root.Walk(func(tag *HTMLTag) bool {
+			if tag.Name == "img" {
+				link := NewHTMLTag("")
+				link.Name = "a"
+				link.Attrs["href"] = tag.Attrs["src"]
+				tag.ReplaceWith(link.AppendChild(tag))
+			}
+			return true
+		})
+	}
+	in := "[img]a.png[/img] [b][img]b.png[/img][/b]"
+	expected := `<a href="a.png"><img src="a.png"></a> <b><a href="b.png"><img src="b.png"></a></b>`
+	if result := c.Compile(in); result != expected {
+		t.Errorf("Failed to wrap tags during Walk.\nExpected: %s, got: %s\n", expected, result)
+	}
+}
+
+func TestPostCompile(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	c.PostCompile = func(root *HTMLTag) {
+		for _, img := range root.FindAll("img") {
+			img.Attrs["loading"] = "lazy"
+		}
+	}
+	expected := `<b><img loading="lazy" src="a.png"></b>`
+	if result := c.Compile("[b][img]a.png[/img][/b]"); result != expected {
+		t.Errorf("Failed to run PostCompile.\nExpected: %s, got: %s\n", expected, result)
+	}
+	if result := c.CompileResult("[b][img]a.png[/img][/b]").HTML; result != expected {
+		t.Errorf("Failed to run PostCompile.\nExpected: %s, got: %s\n", expected, result)
+	}
+}
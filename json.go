@@ -0,0 +1,164 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// JSONVersion is the version of the JSON schema written by MarshalJSON.
+//
+// A tree is written as a document holding the version and the root node:
+//
+//	{"version": 1, "root": NODE}
+//
+// Each NODE is an object with these fields, where empty fields are omitted:
+//
+//	type      "text", "opening" or "closing", the ID of the node's token.
+//	text      The text of a text node.
+//	name      The lowercased tag name of an opening or closing tag.
+//	value     The value of an opening tag, as in [url=value].
+//	args      The arguments of an opening tag, as an array of
+//	          {"name": ..., "value": ...} objects sorted by name.
+//	raw       The source of an opening or closing tag, output when the tag
+//	          is not compiled.
+//	closing   The closing tag matched to an opening tag, as an object
+//	          with "raw" and "pos" fields. Unclosed tags have none.
+//	pos       The byte offset of the node in the input.
+//	children  The child nodes.
+//
+// The root of a parsed tree is a text node holding any text before the
+// first tag. Readers should reject documents with a newer version.
+const JSONVersion = 1
+
+type jsonDocument struct {
+	Version int       `json:"version"`
+	Root    *jsonNode `json:"root"`
+}
+
+type jsonNode struct {
+	Type     string       `json:"type"`
+	Text     string       `json:"text,omitempty"`
+	Name     string       `json:"name,omitempty"`
+	Value    string       `json:"value,omitempty"`
+	Args     []jsonArg    `json:"args,omitempty"`
+	Raw      string       `json:"raw,omitempty"`
+	Closing  *jsonClosing `json:"closing,omitempty"`
+	Pos      int          `json:"pos"`
+	Children []*jsonNode  `json:"children,omitempty"`
+}
+
+type jsonArg struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type jsonClosing struct {
+	Raw string `json:"raw"`
+	Pos int    `json:"pos"`
+}
+
+// MarshalJSON writes the tree under n as a versioned JSON document. The
+// Compiler and Info fields of the nodes are not included.
+func (n *BBCodeNode) MarshalJSON() ([]byte, error) {
+	root, err := toJSONNode(n)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonDocument{JSONVersion, root})
+}
+
+// UnmarshalJSON rebuilds a tree written by MarshalJSON into n, which becomes
+// its root.
+func (n *BBCodeNode) UnmarshalJSON(data []byte) error {
+	var doc jsonDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if doc.Version < 1 || doc.Version > JSONVersion {
+		return fmt.Errorf("bbcode: unsupported JSON version %d", doc.Version)
+	}
+	if doc.Root == nil {
+		return errors.New("bbcode: JSON document has no root")
+	}
+	root, err := fromJSONNode(doc.Root, nil)
+	if err != nil {
+		return err
+	}
+	*n = *root
+	for _, child := range n.Children {
+		child.Parent = n
+	}
+	return nil
+}
+
+func toJSONNode(n *BBCodeNode) (*jsonNode, error) {
+	out := &jsonNode{Type: n.ID, Pos: n.Pos}
+	switch value := n.Value.(type) {
+	case string:
+		out.Text = value
+	case BBOpeningTag:
+		out.Name = value.Name
+		out.Value = value.Value
+		out.Raw = value.Raw
+		for name, v := range value.Args {
+			out.Args = append(out.Args, jsonArg{name, v})
+		}
+		sort.Slice(out.Args, func(i, j int) bool { return out.Args[i].Name < out.Args[j].Name })
+		if n.ClosingTag != nil {
+			out.Closing = &jsonClosing{n.ClosingTag.Raw, n.ClosingTag.Pos}
+		}
+	case BBClosingTag:
+		out.Name = value.Name
+		out.Raw = value.Raw
+	default:
+		return nil, fmt.Errorf("bbcode: can't marshal %s node with value %T", n.ID, n.Value)
+	}
+	for _, child := range n.Children {
+		c, err := toJSONNode(child)
+		if err != nil {
+			return nil, err
+		}
+		out.Children = append(out.Children, c)
+	}
+	return out, nil
+}
+
+func fromJSONNode(in *jsonNode, parent *BBCodeNode) (*BBCodeNode, error) {
+	n := &BBCodeNode{Parent: parent, Children: make([]*BBCodeNode, 0, len(in.Children))}
+	n.ID = in.Type
+	n.Pos = in.Pos
+	switch in.Type {
+	case TEXT:
+		n.Value = in.Text
+	case OPENING_TAG:
+		tag := BBOpeningTag{Name: in.Name, Value: in.Value, Args: make(map[string]string, len(in.Args)), Raw: in.Raw}
+		for _, arg := range in.Args {
+			tag.Args[arg.Name] = arg.Value
+		}
+		n.Value = tag
+		if in.Closing != nil {
+			n.ClosingTag = &BBClosingTag{Name: in.Name, Raw: in.Closing.Raw, Pos: in.Closing.Pos}
+		}
+	case CLOSING_TAG:
+		n.Value = BBClosingTag{Name: in.Name, Raw: in.Raw, Pos: in.Pos}
+	default:
+		return nil, fmt.Errorf("bbcode: unknown JSON node type %q", in.Type)
+	}
+	for _, child := range in.Children {
+		if child == nil {
+			return nil, errors.New("bbcode: JSON node is null")
+		}
+		c, err := fromJSONNode(child, n)
+		if err != nil {
+			return nil, err
+		}
+		n.Children = append(n.Children, c)
+	}
+	return n, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONSchema(t *testing.T) {
+	in := "a[url=http://example.com foo=1]b[/URL][b]c[/i]"
+	expected := `{"version":1,"root":{"type":"text","text":"a","pos":0,"children":[` +
+		`{"type":"opening","name":"url","value":"http://example.com","args":[{"name":"foo","value":"1"}],"raw":"[url=http://example.com foo=1]","closing":{"raw":"[/URL]","pos":32},"pos":1,"children":[{"type":"text","text":"b","pos":31}]},` +
+		`{"type":"opening","name":"b","raw":"[b]","pos":38,"children":[{"type":"text","text":"c","pos":41},{"type":"closing","name":"i","raw":"[/i]","pos":42}]}]}}`
+	data, err := json.Marshal(Parse(Lex(in)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != expected {
+		t.Errorf("Failed to marshal %s.\nExpected: %s, got: %s\n", in, expected, data)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	var inputs []string
+	for _, tests := range []map[string]string{basicTests, brokenTests, paragraphTests, repairTests} {
+		for in := range tests {
+			inputs = append(inputs, in)
+		}
+	}
+	compilers := []Compiler{NewCompiler(false, false), NewCompiler(true, true)}
+	compilers[1].Paragraphs = true
+	for _, in := range inputs {
+		tree := Parse(Lex(in))
+		data, err := json.Marshal(tree)
+		if err != nil {
+			t.Fatalf("Failed to marshal %s: %s\n", in, err)
+		}
+		var decoded BBCodeNode
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Failed to unmarshal %s: %s\n", in, err)
+		}
+		checkParents(t, &decoded)
+		if again, _ := json.Marshal(&decoded); string(again) != string(data) {
+			t.Errorf("Round trip of %s changed the JSON.\nExpected: %s, got: %s\n", in, data, again)
+		}
+		for _, c := range compilers {
+			c.SortOutputAttributes = true
+			out := c.CompileTree(Parse(Lex(in))).Compile(true)
+			result := c.CompileTree(&decoded).Compile(true)
+			if result != out {
+				t.Errorf("Failed to compile decoded %s.\nExpected: %s, got: %s\n", in, out, result)
+			}
+		}
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	tests := map[string]string{
+		`{"version":2,"root":{"type":"text"}}`: "unsupported JSON version 2",
+		`{"version":1}`:                        "no root",
+		`{"version":1,"root":{"type":"text","children":[{"type":"image"}]}}`: "unknown JSON node type",
+		`{"version":1,"root":{"type":"text","children":[null]}}`:             "null",
+	}
+	for in, message := range tests {
+		var node BBCodeNode
+		err := json.Unmarshal([]byte(in), &node)
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Failed to reject %s.\nExpected: %s, got: %v\n", in, message, err)
+		}
+	}
+}
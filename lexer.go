@@ -12,15 +12,17 @@ import (
 type Token struct {
 	ID    string
 	Value interface{}
+	Pos   int // Byte offset of the token in the input.
 }
 
 type lexer struct {
 	input  string
 	tokens chan Token
 
-	start int
-	end   int
-	pos   int
+	start  int
+	end    int
+	pos    int
+	offset int
 
 	tagName     string
 	tagValue    string
@@ -59,8 +61,9 @@ func (l *lexer) emit(id string, value interface{}) {
 	if l.pos > 0 {
 		// fmt.Println(l.input)
 		// fmt.Printf("Emit %s: %+v\n", id, value)
-		l.tokens <- Token{id, value}
+		l.tokens <- Token{ID: id, Value: value, Pos: l.offset}
 		l.input = l.input[l.pos:]
+		l.offset += l.pos
 		l.pos = 0
 	}
 }
@@ -116,7 +119,7 @@ func lexClosingTag(l *lexer) stateFn {
 			return lexText
 		case ']':
 			l.pos++
-			l.emit(CLOSING_TAG, BBClosingTag{strings.ToLower(l.input[l.start:l.end]), l.input[:l.pos]})
+			l.emit(CLOSING_TAG, BBClosingTag{strings.ToLower(l.input[l.start:l.end]), l.input[:l.pos], l.offset})
 			return lexText
 		case ' ', '\t', '\n':
 			whiteSpace = true
@@ -0,0 +1,188 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MediaProvider describes a site whose media can be embedded with the
+// [media] and [video] tags, or with a tag of the provider's name.
+type MediaProvider struct {
+	// Title is the title of the iframe, for screen readers. The
+	// media.NAME message, if any, is used instead.
+	Title string
+	// Pattern matches the URLs or IDs of the provider's media. The first
+	// non-empty capture group is the ID.
+	Pattern *regexp.Regexp
+	// EmbedURL is the URL of the player. {id} is replaced with the ID and
+	// {url} with the matched URL, both escaped.
+	EmbedURL string
+	// Width and Height are the size of the iframe.
+	Width  string
+	Height string
+	// Allow is the permissions policy of the iframe.
+	Allow string
+	// Sandbox is the sandbox of the iframe. If empty, DefaultMediaSandbox
+	// is used.
+	Sandbox string
+}
+
+// DefaultMediaSandbox lets embedded players run and go fullscreen, but not
+// navigate the page.
+const DefaultMediaSandbox = "allow-scripts allow-same-origin allow-presentation allow-popups"
+
+const mediaAllow = "accelerometer; autoplay; clipboard-write; encrypted-media; gyroscope; picture-in-picture; fullscreen"
+
+// DefaultMediaProviders holds the built-in providers. They are not enabled
+// until they are allowed on a compiler with SetMediaProvider.
+var DefaultMediaProviders = map[string]*MediaProvider{
+	"youtube": {
+		Title: "YouTube video",
+		Pattern: regexp.MustCompile(`^(?:https?://)?(?:www\.|m\.)?(?:youtube\.com/(?:watch\?(?:[^#]*&)?v=|embed/|shorts/)|youtu\.be/)([\w-]{11})(?:[?&#].*)?$` +
+			`|^([\w-]{11})$`),
+		EmbedURL: "https://www.youtube-nocookie.com/embed/{id}",
+		Width:    "560",
+		Height:   "315",
+		Allow:    mediaAllow,
+	},
+	"vimeo": {
+		Title:    "Vimeo video",
+		Pattern:  regexp.MustCompile(`^(?:https?://)?(?:www\.|player\.)?vimeo\.com/(?:video/)?(\d+)(?:[/?#].*)?$|^(\d+)$`),
+		EmbedURL: "https://player.vimeo.com/video/{id}?dnt=1",
+		Width:    "640",
+		Height:   "360",
+		Allow:    mediaAllow,
+	},
+	"soundcloud": {
+		Title:    "SoundCloud audio",
+		Pattern:  regexp.MustCompile(`^https://soundcloud\.com/[\w-]+/[\w-]+(?:/[\w-]+)?/?$`),
+		EmbedURL: "https://w.soundcloud.com/player/?url={url}",
+		Width:    "100%",
+		Height:   "166",
+		Allow:    "autoplay",
+	},
+}
+
+// videoFile matches links to video files, which [video] plays with a
+// <video> element.
+var videoFile = regexp.MustCompile(`(?i)^https?://[^?#]+\.(?:mp4|webm|ogv)(?:[?#].*)?$`)
+
+// SetMediaProvider allows a media provider under name, and adds a tag of
+// that name that embeds media from it, unless the compiler already has a tag
+// of that name. Setting a nil provider removes the provider, and the tag if
+// it was added by SetMediaProvider.
+func (c Compiler) SetMediaProvider(name string, provider *MediaProvider) {
+	if provider == nil {
+		delete(c.mediaProviders, name)
+		if c.mediaTags[name] {
+			delete(c.mediaTags, name)
+			c.SetTag(name, nil)
+			delete(c.tagOptions, name)
+		}
+		return
+	}
+	c.mediaProviders[name] = provider
+	if _, ok := c.tagCompilers[name]; ok && !c.mediaTags[name] {
+		return
+	}
+	c.mediaTags[name] = true
+	c.SetTag(name, func(node *BBCodeNode) (*HTMLTag, bool) {
+		return node.Compiler.embedMedia(node, []string{name}, false), false
+	})
+	c.SetTagOptions(name, TagOptions{Verbatim: true})
+}
+
+// allowedMediaProviders returns the names of the providers allowed on the
+// compiler, sorted.
+func (c Compiler) allowedMediaProviders() []string {
+	names := make([]string, 0, len(c.mediaProviders))
+	for name := range c.mediaProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// embedMedia compiles a media tag, embedding its URL with the first of the
+// named providers that matches it. Unmatched URLs are output as links, or as
+// <video> elements for video files if video is set.
+func (c Compiler) embedMedia(node *BBCodeNode, providers []string, video bool) *HTMLTag {
+	source := strings.TrimSpace(node.GetOpeningTag().Value)
+	if source == "" {
+		source = strings.TrimSpace(CompileText(node))
+	}
+	for _, name := range providers {
+		provider := c.mediaProviders[name]
+		if provider == nil {
+			continue
+		}
+		title := c.Message("media." + name)
+		if title == "" {
+			title = provider.Title
+		}
+		if iframe, ok := provider.embed(source, title); ok {
+			return iframe
+		}
+	}
+
+	out := NewHTMLTag("")
+	u, err := url.Parse(source)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return out.AppendChild(NewHTMLTag(source))
+	}
+	if video && videoFile.MatchString(source) {
+		out.Name = "video"
+		out.Attrs["src"] = u.String()
+		out.Attrs["controls"] = ""
+		out.Attrs["preload"] = "metadata"
+		link := NewHTMLTag("")
+		link.Name = "a"
+		link.Attrs["href"] = u.String()
+		return out.AppendChild(link.AppendChild(NewHTMLTag(source)))
+	}
+	out.Name = "a"
+	out.Attrs["href"] = u.String()
+	return out.AppendChild(NewHTMLTag(source))
+}
+
+// embed returns the iframe for source, if it matches the provider.
+func (p *MediaProvider) embed(source, title string) (*HTMLTag, bool) {
+	match := p.Pattern.FindStringSubmatch(source)
+	if match == nil {
+		return nil, false
+	}
+	id := ""
+	for _, group := range match[1:] {
+		if group != "" {
+			id = group
+			break
+		}
+	}
+	src := strings.NewReplacer("{id}", url.PathEscape(id), "{url}", url.QueryEscape(match[0])).Replace(p.EmbedURL)
+
+	out := NewHTMLTag("")
+	out.Name = "iframe"
+	out.Attrs["src"] = src
+	for attr, value := range map[string]string{"title": title, "width": p.Width, "height": p.Height} {
+		if value != "" {
+			out.Attrs[attr] = value
+		}
+	}
+	out.Attrs["loading"] = "lazy"
+	out.Attrs["referrerpolicy"] = "strict-origin-when-cross-origin"
+	out.Attrs["sandbox"] = p.Sandbox
+	if p.Sandbox == "" {
+		out.Attrs["sandbox"] = DefaultMediaSandbox
+	}
+	if p.Allow != "" {
+		out.Attrs["allow"] = p.Allow
+		out.Attrs["allowfullscreen"] = ""
+	}
+	return out.AppendChild(NewHTMLTag("")), true
+}
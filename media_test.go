@@ -0,0 +1,102 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"regexp"
+	"testing"
+)
+
+const youtubeFrame = `<iframe allow="` + mediaAllow + `" allowfullscreen="" height="315" loading="lazy" referrerpolicy="strict-origin-when-cross-origin" ` +
+	`sandbox="` + DefaultMediaSandbox + `" src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ" title="YouTube video" width="560"></iframe>`
+
+var mediaTests = map[string]string{
+	"[media]https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=1s[/media]":     youtubeFrame,
+	"[media]https://youtu.be/dQw4w9WgXcQ[/media]":                         youtubeFrame,
+	"[media=https://m.youtube.com/watch?feature=x&v=dQw4w9WgXcQ][/media]": youtubeFrame,
+	"[youtube]dQw4w9WgXcQ[/youtube]":                                      youtubeFrame,
+	"[video] https://www.youtube.com/shorts/dQw4w9WgXcQ [/video]":         youtubeFrame,
+	"[youtube]https://vimeo.com/76979871[/youtube]":                       `<a href="https://vimeo.com/76979871">https://vimeo.com/76979871</a>`,
+	"[media]https://vimeo.com/76979871[/media]": `<iframe allow="` + mediaAllow + `" allowfullscreen="" height="360" loading="lazy" referrerpolicy="strict-origin-when-cross-origin" ` +
+		`sandbox="` + DefaultMediaSandbox + `" src="https://player.vimeo.com/video/76979871?dnt=1" title="Vimeo video" width="640"></iframe>`,
+	"[media]https://soundcloud.com/artist/track[/media]": `<iframe allow="autoplay" allowfullscreen="" height="166" loading="lazy" referrerpolicy="strict-origin-when-cross-origin" ` +
+		`sandbox="` + DefaultMediaSandbox + `" src="https://w.soundcloud.com/player/?url=https%3A%2F%2Fsoundcloud.com%2Fartist%2Ftrack" title="SoundCloud audio" width="100%"></iframe>`,
+	"[media]https://example.com/page[/media]":       `<a href="https://example.com/page">https://example.com/page</a>`,
+	"[media]javascript:alert(1)[/media]":            `javascript:alert(1)`,
+	"[media]<b>not a url</b>[/media]":               `&lt;b&gt;not a url&lt;/b&gt;`,
+	"[video]https://example.com/a.mp4[/video]":      `<video controls="" preload="metadata" src="https://example.com/a.mp4"><a href="https://example.com/a.mp4">https://example.com/a.mp4</a></video>`,
+	"[media]https://example.com/a.mp4[/media]":      `<a href="https://example.com/a.mp4">https://example.com/a.mp4</a>`,
+	"[media]https://youtu.be/dQw4w9WgXcQ\"[/media]": `<a href="https://youtu.be/dQw4w9WgXcQ%22">https://youtu.be/dQw4w9WgXcQ&#34;</a>`,
+}
+
+func TestMediaEmbeds(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	c.AutoLink = true
+	for name, provider := range DefaultMediaProviders {
+		c.SetMediaProvider(name, provider)
+	}
+	for in, out := range mediaTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
+
+func TestMediaAllowList(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	in := "[media]https://youtu.be/dQw4w9WgXcQ[/media]"
+	out := `<a href="https://youtu.be/dQw4w9WgXcQ">https://youtu.be/dQw4w9WgXcQ</a>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+	if result := c.Compile("[youtube]dQw4w9WgXcQ[/youtube]"); result != "[youtube]dQw4w9WgXcQ[/youtube]" {
+		t.Errorf("Provider tag exists before it is allowed: %s\n", result)
+	}
+
+	c.SetMediaProvider("youtube", DefaultMediaProviders["youtube"])
+	if result := c.Compile(in); result != youtubeFrame {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, youtubeFrame, result)
+	}
+	c.SetMediaProvider("youtube", nil)
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+
+	c.SetMediaProvider("clips", &MediaProvider{
+		Pattern:  regexp.MustCompile(`^clip:(\w+)$`),
+		EmbedURL: "https://clips.example.com/{id}",
+		Sandbox:  "allow-scripts",
+	})
+	in = "[clips]clip:abc[/clips]"
+	out = `<iframe loading="lazy" referrerpolicy="strict-origin-when-cross-origin" sandbox="allow-scripts" src="https://clips.example.com/abc"></iframe>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+}
+
+func TestMediaProviderNameClash(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	c.SetMediaProvider("video", DefaultMediaProviders["youtube"])
+	in := "[video]https://example.com/a.mp4[/video]"
+	out := `<video controls="" preload="metadata" src="https://example.com/a.mp4"><a href="https://example.com/a.mp4">https://example.com/a.mp4</a></video>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to keep built-in tag %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+	if result := c.Compile("[video]https://youtu.be/dQw4w9WgXcQ[/video]"); result != youtubeFrame {
+		t.Errorf("Failed to embed with provider named video: %s\n", result)
+	}
+
+	c.SetMediaProvider("video", nil)
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to keep built-in tag %s after removing provider.\nExpected: %s, got: %s\n", in, out, result)
+	}
+	if result := c.Compile("[video]https://youtu.be/dQw4w9WgXcQ[/video]"); result == youtubeFrame {
+		t.Errorf("Embedded with removed provider: %s\n", result)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MentionResolver resolves the name in an @mention to a profile URL.
+// Mentions that don't resolve are left as text.
+type MentionResolver interface {
+	Resolve(name string) (url string, ok bool)
+}
+
+// MentionResolverFunc is an adapter to allow the use of ordinary functions
+// as mention resolvers.
+type MentionResolverFunc func(name string) (string, bool)
+
+func (f MentionResolverFunc) Resolve(name string) (string, bool) {
+	return f(name)
+}
+
+// HashtagResolver resolves the tag in a #hashtag to a URL. Hashtags that
+// don't resolve are left as text.
+type HashtagResolver interface {
+	Resolve(tag string) (url string, ok bool)
+}
+
+// HashtagResolverFunc is an adapter to allow the use of ordinary functions
+// as hashtag resolvers.
+type HashtagResolverFunc func(tag string) (string, bool)
+
+func (f HashtagResolverFunc) Resolve(tag string) (string, bool) {
+	return f(tag)
+}
+
+// Mention is a resolved @mention.
+type Mention struct {
+	Name string
+	URL  string
+}
+
+// Hashtag is a resolved #hashtag.
+type Hashtag struct {
+	Tag string
+	URL string
+}
+
+// Result is the output of CompileResult.
+type Result struct {
+	HTML string
+	// Mentions and Hashtags list each resolved URL once, in the order
+	// they first appear.
+	Mentions []Mention
+	Hashtags []Hashtag
+}
+
+// CompileResult is like Compile, but also reports the mentions and hashtags
+// resolved while compiling.
+func (c Compiler) CompileResult(str string) Result {
+	result := &Result{}
+	c.result = result
+	result.HTML = c.render(c.parse(str))
+	return *result
+}
+
+// MentionFilter links @mentions and #hashtags when the compiler has a
+// Mentions or Hashtags resolver.
+var MentionFilter NodeTextFilter = mentionFilter{}
+
+type mentionFilter struct{}
+
+func (mentionFilter) enabled(c *Compiler) bool {
+	return c.Mentions != nil || c.Hashtags != nil
+}
+
+func (mentionFilter) Filter(text string) []*HTMLTag {
+	return []*HTMLTag{NewHTMLTag(text)}
+}
+
+func (f mentionFilter) FilterNode(node *BBCodeNode, text string) []*HTMLTag {
+	c := node.Compiler
+	if !f.enabled(c) {
+		return []*HTMLTag{NewHTMLTag(text)}
+	}
+	var out []*HTMLTag
+	start := 0
+	for _, m := range findMentions(text) {
+		var url string
+		var ok bool
+		if m.hashtag && c.Hashtags != nil {
+			url, ok = c.Hashtags.Resolve(m.name)
+		} else if !m.hashtag && c.Mentions != nil {
+			url, ok = c.Mentions.Resolve(m.name)
+		}
+		if !ok {
+			continue
+		}
+		if m.start > start {
+			out = append(out, NewHTMLTag(text[start:m.start]))
+		}
+		link := NewHTMLTag("")
+		link.Name = "a"
+		link.Attrs["href"] = ValidURL(url)
+		if m.hashtag {
+			link.Attrs["class"] = "hashtag"
+			c.result.addHashtag(Hashtag{m.name, url})
+		} else {
+			link.Attrs["class"] = "mention"
+			c.result.addMention(Mention{m.name, url})
+		}
+		link.AppendChild(NewHTMLTag(text[m.start:m.end]))
+		out = append(out, link)
+		start = m.end
+	}
+	if start < len(text) {
+		out = append(out, NewHTMLTag(text[start:]))
+	}
+	return out
+}
+
+func (r *Result) addMention(m Mention) {
+	if r == nil {
+		return
+	}
+	for _, existing := range r.Mentions {
+		if existing.URL == m.URL {
+			return
+		}
+	}
+	r.Mentions = append(r.Mentions, m)
+}
+
+func (r *Result) addHashtag(h Hashtag) {
+	if r == nil {
+		return
+	}
+	for _, existing := range r.Hashtags {
+		if existing.URL == h.URL {
+			return
+		}
+	}
+	r.Hashtags = append(r.Hashtags, h)
+}
+
+type mentionMatch struct {
+	start, end int
+	name       string
+	hashtag    bool
+}
+
+// findMentions returns the @mention and #hashtag candidates in text. The
+// marker must not follow a letter, digit or one of @#&/, so email addresses
+// and URL fragments are skipped. Hashtags must contain a letter.
+func findMentions(text string) []mentionMatch {
+	var matches []mentionMatch
+	prev := ' '
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if (r == '@' || r == '#') && !isNameRune(prev) && !strings.ContainsRune("@#&/", prev) {
+			end := i + size
+			for end < len(text) {
+				next, nextSize := utf8.DecodeRuneInString(text[end:])
+				if !isNameRune(next) && (r == '#' || (next != '.' && next != '-')) {
+					break
+				}
+				end += nextSize
+			}
+			name := strings.TrimRight(text[i+size:end], ".-")
+			end = i + size + len(name)
+			if name != "" && (r == '@' || strings.IndexFunc(name, unicode.IsLetter) >= 0) {
+				matches = append(matches, mentionMatch{i, end, name, r == '#'})
+				i, prev = end, 'x'
+				continue
+			}
+		}
+		prev = r
+		i += size
+	}
+	return matches
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
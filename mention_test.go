@@ -0,0 +1,86 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var users = map[string]bool{"alice": true, "bob": true, "bob.smith": true}
+
+func resolveUser(name string) (string, bool) {
+	if users[strings.ToLower(name)] {
+		return "/u/" + strings.ToLower(name), true
+	}
+	return "", false
+}
+
+func resolveTag(tag string) (string, bool) {
+	return "/t/" + strings.ToLower(tag), tag != "unknown"
+}
+
+var mentionTests = map[string]string{
+	"hi @alice":                    `hi <a class="mention" href="/u/alice">@alice</a>`,
+	"@Bob, @carol and @alice.":     `<a class="mention" href="/u/bob">@Bob</a>, @carol and <a class="mention" href="/u/alice">@alice</a>.`,
+	"cc @bob.smith.":               `cc <a class="mention" href="/u/bob.smith">@bob.smith</a>.`,
+	"mail bob@alice.com":           `mail bob@alice.com`,
+	"@@alice":                      `@@alice`,
+	"(@alice)":                     `(<a class="mention" href="/u/alice">@alice</a>)`,
+	"#golang #1 #unknown":          `<a class="hashtag" href="/t/golang">#golang</a> #1 #unknown`,
+	"a#golang &#39;":               `a#golang &amp;#39;`,
+	"#Go_1":                        `<a class="hashtag" href="/t/go_1">#Go_1</a>`,
+	"[b]@alice[/b]":                `<b><a class="mention" href="/u/alice">@alice</a></b>`,
+	"[code]@alice[/code]":          `<pre>@alice</pre>`,
+	"[url=/x]@alice #golang[/url]": `<a href="/x">@alice #golang</a>`,
+	"[quote=@alice]x[/quote]":      `<blockquote><cite>@alice said:</cite>x</blockquote>`,
+}
+
+func TestMentions(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	c.Mentions = MentionResolverFunc(resolveUser)
+	c.Hashtags = HashtagResolverFunc(resolveTag)
+	for in, out := range mentionTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
+
+func TestMentionsAutoLink(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	c.AutoLink = true
+	c.Mentions = MentionResolverFunc(resolveUser)
+	c.Hashtags = HashtagResolverFunc(resolveTag)
+	in := "http://example.com/#golang alice@example.com @alice"
+	out := `<a href="http://example.com/#golang">http://example.com/#golang</a> <a href="mailto:alice@example.com">alice@example.com</a> <a class="mention" href="/u/alice">@alice</a>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+}
+
+func TestCompileResult(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	c.Mentions = MentionResolverFunc(resolveUser)
+	c.Hashtags = HashtagResolverFunc(resolveTag)
+	in := "@alice @bob [b]@Alice @carol[/b] [code]@bob.smith[/code] #go #unknown #Go"
+	result := c.CompileResult(in)
+	if result.HTML != c.Compile(in) {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, c.Compile(in), result.HTML)
+	}
+	mentions := []Mention{{"alice", "/u/alice"}, {"bob", "/u/bob"}}
+	if !reflect.DeepEqual(result.Mentions, mentions) {
+		t.Errorf("Failed to collect mentions from %s.\nExpected: %v, got: %v\n", in, mentions, result.Mentions)
+	}
+	hashtags := []Hashtag{{"go", "/t/go"}}
+	if !reflect.DeepEqual(result.Hashtags, hashtags) {
+		t.Errorf("Failed to collect hashtags from %s.\nExpected: %v, got: %v\n", in, hashtags, result.Hashtags)
+	}
+}
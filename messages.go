@@ -0,0 +1,99 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"context"
+	"strings"
+)
+
+// Messages is a catalog of the text output by the default tags, in one
+// language. Keys missing from a catalog fall back to DefaultMessages.
+type Messages interface {
+	// Language identifies the catalog, such as "en" or "pt-BR". Prepared
+	// documents keep their output separately for each language.
+	Language() string
+	Message(key string) (string, bool)
+}
+
+// Catalog is a catalog of messages stored in a map.
+type Catalog struct {
+	Lang     string
+	Messages map[string]string
+}
+
+func (c *Catalog) Language() string {
+	return c.Lang
+}
+
+func (c *Catalog) Message(key string) (string, bool) {
+	message, ok := c.Messages[key]
+	return message, ok
+}
+
+// DefaultMessages is the English catalog used by default. Its keys are:
+//
+//	quote                 citation of quotes without an author
+//	quote.said            citation of quotes by {author}
+//	quote.time            Go time layout of quote times without a QuoteResolver
+//	quote.more            summary of collapsed nested quotes
+//	spoiler               summary of spoilers without a title
+//	attachment.missing    placeholder for attachments that don't resolve
+//	attachment.forbidden  placeholder for attachments the viewer may not see
+//	media.NAME            title of iframes from the media provider NAME
+var DefaultMessages Messages = &Catalog{
+	Lang: "en",
+	Messages: map[string]string{
+		"quote":                "Quote",
+		"quote.said":           "{author} said:",
+		"quote.time":           "2006-01-02 15:04",
+		"quote.more":           "Show more",
+		"spoiler":              "Spoiler",
+		"attachment.missing":   "Attachment unavailable",
+		"attachment.forbidden": "You do not have permission to view this attachment",
+		"media.youtube":        "YouTube video",
+		"media.vimeo":          "Vimeo video",
+		"media.soundcloud":     "SoundCloud audio",
+	},
+}
+
+type messagesKey struct{}
+
+// WithMessages returns a copy of ctx that selects messages for compiles with
+// it, such as the renders of a Document, overriding Compiler.Messages.
+func WithMessages(ctx context.Context, messages Messages) context.Context {
+	return context.WithValue(ctx, messagesKey{}, messages)
+}
+
+// messages returns the catalog of the compile in progress.
+func (c Compiler) messages() Messages {
+	if messages, ok := c.Context().Value(messagesKey{}).(Messages); ok && messages != nil {
+		return messages
+	}
+	if c.Messages != nil {
+		return c.Messages
+	}
+	return DefaultMessages
+}
+
+// Message returns the message with key in the catalog of the compile in
+// progress, or "" if neither it nor DefaultMessages has one.
+func (c Compiler) Message(key string) string {
+	if message, ok := c.messages().Message(key); ok {
+		return message
+	}
+	message, _ := DefaultMessages.Message(key)
+	return message
+}
+
+// expandMessage splits message around {name}, returning the text before and
+// after it. If message has no {name}, it is all returned as before.
+func expandMessage(message, name string) (before, after string, ok bool) {
+	i := strings.Index(message, "{"+name+"}")
+	if i < 0 {
+		return message, "", false
+	}
+	return message[:i], message[i+len(name)+2:], true
+}
@@ -0,0 +1,120 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"context"
+	"testing"
+)
+
+var germanMessages = &Catalog{
+	Lang: "de",
+	Messages: map[string]string{
+		"quote":              "Zitat",
+		"quote.said":         "{author} schrieb:",
+		"quote.time":         "02.01.2006 15:04",
+		"quote.more":         "Mehr anzeigen",
+		"spoiler":            "Spoiler anzeigen",
+		"attachment.missing": "Anhang nicht verfügbar",
+		"media.youtube":      "YouTube-Video",
+	},
+}
+
+var japaneseMessages = &Catalog{
+	Lang: "ja",
+	Messages: map[string]string{
+		"quote.said": "{author}さんの投稿:",
+	},
+}
+
+var messageTests = map[string]string{
+	"[quote]hi[/quote]":                          `<blockquote><cite>Zitat</cite>hi</blockquote>`,
+	"[quote=Bob]hi[/quote]":                      `<blockquote><cite>Bob schrieb:</cite>hi</blockquote>`,
+	"[quote name=Bob time=1700000000]hi[/quote]": `<blockquote><cite>Bob schrieb: <time datetime="2023-11-14T22:13:20Z">14.11.2023 22:13</time></cite>hi</blockquote>`,
+	"[quote=A][quote=B]b[/quote][/quote]":        `<blockquote><cite>A schrieb:</cite><details class="quote-more"><summary>Mehr anzeigen</summary><blockquote><cite>B schrieb:</cite>b</blockquote></details></blockquote>`,
+	"[spoiler]text[/spoiler]":                    `<details><summary>Spoiler anzeigen</summary>text</details>`,
+	"[spoiler=Titel]text[/spoiler]":              `<details><summary>Titel</summary>text</details>`,
+	"[attach]7[/attach]":                         `<span class="attachment attachment-missing">Anhang nicht verfügbar</span>`,
+	"[attach]6[/attach]":                         `<span class="attachment attachment-forbidden">You do not have permission to view this attachment</span>`,
+	"[youtube]dQw4w9WgXcQ[/youtube]":             `<iframe allow="` + mediaAllow + `" allowfullscreen="" height="315" loading="lazy" referrerpolicy="strict-origin-when-cross-origin" sandbox="` + DefaultMediaSandbox + `" src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ" title="YouTube-Video" width="560"></iframe>`,
+}
+
+func messagesCompiler() Compiler {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	c.MaxQuoteDepth = 1
+	c.Attachments = attachmentResolver()
+	c.SetMediaProvider("youtube", DefaultMediaProviders["youtube"])
+	return c
+}
+
+func TestMessages(t *testing.T) {
+	c := messagesCompiler()
+	c.Messages = germanMessages
+	for in, out := range messageTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
+
+func TestMessagesFromContext(t *testing.T) {
+	c := messagesCompiler()
+	c.Messages = japaneseMessages
+	tests := []struct {
+		ctx context.Context
+		in  string
+		out string
+	}{
+		{context.Background(), "[quote=Bob]hi[/quote]", `<blockquote><cite>Bobさんの投稿:</cite>hi</blockquote>`},
+		{context.Background(), "[quote]hi[/quote]", `<blockquote><cite>Quote</cite>hi</blockquote>`},
+		{WithMessages(context.Background(), germanMessages), "[quote=Bob]hi[/quote]", `<blockquote><cite>Bob schrieb:</cite>hi</blockquote>`},
+		{WithMessages(context.Background(), DefaultMessages), "[quote=Bob]hi[/quote]", `<blockquote><cite>Bob said:</cite>hi</blockquote>`},
+	}
+	for _, test := range tests {
+		result := c.WithContext(test.ctx).Compile(test.in)
+		if result != test.out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", test.in, test.out, result)
+		}
+	}
+}
+
+func TestDocumentMessages(t *testing.T) {
+	c := messagesCompiler()
+	doc := c.Prepare("[spoiler]a[/spoiler] [quote=Bob]b[/quote]")
+	german := WithMessages(context.Background(), germanMessages)
+	tests := []struct {
+		ctx context.Context
+		out string
+	}{
+		{context.Background(), `<details><summary>Spoiler</summary>a</details> <blockquote><cite>Bob said:</cite>b</blockquote>`},
+		{german, `<details><summary>Spoiler anzeigen</summary>a</details> <blockquote><cite>Bob schrieb:</cite>b</blockquote>`},
+		{context.Background(), `<details><summary>Spoiler</summary>a</details> <blockquote><cite>Bob said:</cite>b</blockquote>`},
+		{german, `<details><summary>Spoiler anzeigen</summary>a</details> <blockquote><cite>Bob schrieb:</cite>b</blockquote>`},
+	}
+	for _, test := range tests {
+		if result := doc.Render(test.ctx); result != test.out {
+			t.Errorf("Failed to render document.\nExpected: %s, got: %s\n", test.out, result)
+		}
+	}
+}
+
+func TestExpandMessage(t *testing.T) {
+	tests := []struct {
+		message, before, after string
+		ok                     bool
+	}{
+		{"{author} said:", "", " said:", true},
+		{"Posted by {author}.", "Posted by ", ".", true},
+		{"Quote", "Quote", "", false},
+	}
+	for _, test := range tests {
+		before, after, ok := expandMessage(test.message, "author")
+		if before != test.before || after != test.after || ok != test.ok {
+			t.Errorf("Failed to expand %q.\nExpected: %q, %q, %v, got: %q, %q, %v\n", test.message, test.before, test.after, test.ok, before, after, ok)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"regexp"
+	"strings"
+)
+
+var paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+
+// isBlock reports whether node is compiled as a block-level tag.
+func (c Compiler) isBlock(node *BBCodeNode) bool {
+	if node.ID != OPENING_TAG || (node.ClosingTag == nil && !c.AutoCloseTags) {
+		return false
+	}
+	name := node.Value.(BBOpeningTag).Name
+	if _, ok := c.tagCompilers[name]; !ok {
+		return false
+	}
+	return c.tagOptions[name].Block
+}
+
+// breaksParagraph reports whether node is kept out of paragraphs, because
+// it is a block-level tag or has one inside it.
+func (c Compiler) breaksParagraph(node *BBCodeNode) bool {
+	if c.isBlock(node) {
+		return true
+	}
+	if node.ID != OPENING_TAG || c.tagOptions[node.Value.(BBOpeningTag).Name].Verbatim {
+		return false
+	}
+	for _, child := range node.Children {
+		if c.breaksParagraph(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// noParagraphs lists the elements whose content model doesn't allow <p>,
+// so the contents of block-level tags compiled to them aren't split into
+// paragraphs.
+var noParagraphs = map[string]bool{
+	"dl": true, "menu": true, "ol": true, "ul": true,
+	"table": true, "tbody": true, "tfoot": true, "thead": true, "tr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"p": true, "pre": true, "summary": true,
+}
+
+// flowItem is a piece of inline or block content in paragraph mode.
+type flowItem struct {
+	node *BBCodeNode
+	text string
+	kind int
+}
+
+const (
+	flowNode = iota // A node compiled with CompileTree.
+	flowText        // Text belonging to node.
+	flowRaw         // The raw opening tag of an unclosed node.
+	flowLeaf        // A self-closed node, compiled without its children.
+)
+
+// flatten lists the content of nodes in paragraph mode. Unclosed tags are
+// output as raw text, and self-closed tags on their own, so their children
+// are flattened into the same flow.
+func (c Compiler) flatten(nodes []*BBCodeNode, items []flowItem) []flowItem {
+	for _, node := range nodes {
+		switch {
+		case node.ID == TEXT:
+			items = append(items, flowItem{node, node.Value.(string), flowText})
+		case c.selfClosed(node):
+			items = append(items, flowItem{node: node, kind: flowLeaf})
+			items = c.flatten(node.Children, items)
+		case node.ID == OPENING_TAG && node.ClosingTag == nil && !c.AutoCloseTags:
+			items = append(items, flowItem{node, node.Value.(BBOpeningTag).Raw, flowRaw})
+			items = c.flatten(node.Children, items)
+		default:
+			items = append(items, flowItem{node: node, kind: flowNode})
+		}
+	}
+	return items
+}
+
+// compileParagraphs appends the contents of node to out, grouping inline
+// content into paragraphs separated by blank lines and block-level tags.
+func (c Compiler) compileParagraphs(node *BBCodeNode, out *HTMLTag) {
+	var items []flowItem
+	if node.ID == TEXT && len(node.Value.(string)) > 0 {
+		items = append(items, flowItem{node, node.Value.(string), flowText})
+	}
+	items = c.flatten(node.Children, items)
+
+	para := newParagraph()
+	flush := func() {
+		if !isBlank(para.Children) {
+			out.AppendChild(para)
+		}
+		para = newParagraph()
+	}
+	for i, item := range items {
+		switch {
+		case item.kind == flowRaw:
+			tag := NewHTMLTag(item.text)
+			InsertNewlines(tag)
+			para.AppendChild(tag)
+		case item.kind == flowLeaf:
+			para.AppendChild(c.compileLeaf(item.node))
+		case item.kind == flowNode && c.breaksParagraph(item.node):
+			flush()
+			out.AppendChild(c.CompileTree(item.node))
+		case item.kind == flowNode:
+			para.AppendChild(c.CompileTree(item.node))
+		default:
+			beforeBlock := i+1 == len(items) || (items[i+1].kind == flowNode && c.breaksParagraph(items[i+1].node))
+			parts := paragraphBreak.Split(item.text, -1)
+			for j, part := range parts {
+				if j > 0 {
+					flush()
+				}
+				if len(para.Children) == 0 {
+					part = strings.TrimLeft(part, " \t\r\n")
+				}
+				if j < len(parts)-1 || beforeBlock {
+					part = strings.TrimRight(part, " \t\r\n")
+				}
+				if len(part) > 0 {
+					para.AppendChild(c.compileText(item.node, part))
+				}
+			}
+		}
+	}
+	flush()
+}
+
+// compileInline appends the children of node to out without grouping them
+// into paragraphs. Line breaks next to children that break paragraphs are
+// dropped, as they are between paragraphs, so that they don't add <br>
+// elements around blocks inside inline tags.
+func (c Compiler) compileInline(node *BBCodeNode, out *HTMLTag) {
+	for i, child := range node.Children {
+		if child.ID != TEXT || len(child.Children) > 0 {
+			out.AppendChild(c.CompileTree(child))
+			continue
+		}
+		text := child.Value.(string)
+		if i > 0 && c.breaksParagraph(node.Children[i-1]) {
+			text = strings.TrimLeft(text, "\r\n")
+		}
+		if i+1 < len(node.Children) && c.breaksParagraph(node.Children[i+1]) {
+			text = strings.TrimRight(text, "\r\n")
+		}
+		if text == child.Value.(string) {
+			out.AppendChild(c.CompileTree(child))
+		} else if len(text) > 0 {
+			out.AppendChild(c.compileText(child, text))
+		}
+	}
+}
+
+func newParagraph() *HTMLTag {
+	out := NewHTMLTag("")
+	out.Name = "p"
+	return out
+}
+
+// isBlank reports whether tags render only whitespace.
+func isBlank(tags []*HTMLTag) bool {
+	for _, t := range tags {
+		if t.Name != "" || strings.TrimSpace(t.Value) != "" || !isBlank(t.Children) {
+			return false
+		}
+	}
+	return true
+}
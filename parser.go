@@ -22,6 +22,22 @@ func (n *BBCodeNode) GetOpeningTag() *BBOpeningTag {
 	}
 }
 
+// End returns the byte offset in the input just past the end of the node,
+// including its closing tag.
+func (n *BBCodeNode) End() int {
+	switch {
+	case n.ID == OPENING_TAG && n.ClosingTag != nil:
+		return n.ClosingTag.Pos + len(n.ClosingTag.Raw)
+	case len(n.Children) > 0:
+		return n.Children[len(n.Children)-1].End()
+	case n.ID == TEXT:
+		return n.Pos + len(n.Value.(string))
+	case n.ID == CLOSING_TAG:
+		return n.Pos + len(n.Value.(BBClosingTag).Raw)
+	}
+	return n.Pos + len(n.Value.(BBOpeningTag).Raw)
+}
+
 func (n *BBCodeNode) appendChild(t Token) *BBCodeNode {
 	if t.ID == CLOSING_TAG {
 		curr := n
@@ -51,10 +67,144 @@ func (n *BBCodeNode) appendChild(t Token) *BBCodeNode {
 }
 
 func Parse(tokens chan Token) *BBCodeNode {
-	root := &BBCodeNode{Token{TEXT, ""}, nil, make([]*BBCodeNode, 0, 5), nil, nil, nil}
+	root := &BBCodeNode{Token{TEXT, "", 0}, nil, make([]*BBCodeNode, 0, 5), nil, nil, nil}
 	curr := root
 	for tok := range tokens {
 		curr = curr.appendChild(tok)
 	}
 	return root
 }
+
+// FormattingTags lists the tags that ParseWithRepair closes and reopens
+// when they are crossed by the closing tag of an enclosing tag.
+var FormattingTags = map[string]bool{
+	"b":     true,
+	"i":     true,
+	"u":     true,
+	"s":     true,
+	"color": true,
+	"size":  true,
+}
+
+// ParseWithRepair is like Parse, but repairs crossed tags in the way HTML
+// parsers do. When a closing tag ends a tag that still has open
+// formatting tags inside it, such as the [/b] in [b]a[i]b[/b]c[/i], the
+// formatting tags are closed along with it and reopened after it, so the
+// example is read as [b]a[i]b[/i][/b][i]c[/i]. The tags added by the repair
+// have empty Raw source. Tags inside the Verbatim tags of DefaultTagOptions
+// are left as written.
+func ParseWithRepair(tokens chan Token) *BBCodeNode {
+	return parseWithRepair(tokens, DefaultTagOptions)
+}
+
+func parseWithRepair(tokens chan Token, options map[string]TagOptions) *BBCodeNode {
+	root := &BBCodeNode{Token{TEXT, "", 0}, nil, make([]*BBCodeNode, 0, 5), nil, nil, nil}
+	r := &repairer{
+		options: options,
+		origins: make(map[*BBCodeNode]*BBCodeNode),
+		chains:  make(map[*BBCodeNode][]*BBCodeNode),
+	}
+	curr := root
+	for tok := range tokens {
+		curr = r.appendChild(curr, tok)
+	}
+	r.finish()
+	return root
+}
+
+// repairer tracks the copies of formatting tags reopened by ParseWithRepair.
+type repairer struct {
+	options map[string]TagOptions
+	// origins maps each copy to the tag in the source it continues, and
+	// chains holds that tag followed by its copies.
+	origins map[*BBCodeNode]*BBCodeNode
+	chains  map[*BBCodeNode][]*BBCodeNode
+	copies  []*BBCodeNode
+}
+
+func (r *repairer) appendChild(n *BBCodeNode, t Token) *BBCodeNode {
+	if t.ID != CLOSING_TAG {
+		return n.appendChild(t)
+	}
+	for curr := n; curr.Parent != nil; curr = curr.Parent {
+		if r.options[curr.Value.(BBOpeningTag).Name].Verbatim {
+			return n.appendChild(t)
+		}
+	}
+	closing := t.Value.(BBClosingTag)
+	var crossed []*BBCodeNode
+	curr := n
+	for curr.Parent != nil {
+		name := curr.Value.(BBOpeningTag).Name
+		if name == closing.Name {
+			break
+		}
+		if !FormattingTags[name] {
+			return n.appendChild(t)
+		}
+		crossed = append(crossed, curr)
+		curr = curr.Parent
+	}
+	if curr.Parent == nil || len(crossed) == 0 {
+		return n.appendChild(t)
+	}
+
+	curr.ClosingTag = &closing
+	parent := curr.Parent
+	for i := len(crossed) - 1; i >= 0; i-- {
+		tag := crossed[i]
+		opening := tag.Value.(BBOpeningTag)
+		tag.ClosingTag = &BBClosingTag{Name: opening.Name, Pos: closing.Pos}
+
+		args := make(map[string]string, len(opening.Args))
+		for k, v := range opening.Args {
+			args[k] = v
+		}
+		opening.Args = args
+		opening.Raw = ""
+		reopened := &BBCodeNode{Token{OPENING_TAG, opening, closing.Pos + len(closing.Raw)}, parent, make([]*BBCodeNode, 0, 5), nil, nil, nil}
+		parent.Children = append(parent.Children, reopened)
+		r.track(tag, reopened)
+		parent = reopened
+	}
+	return parent
+}
+
+func (r *repairer) track(tag, reopened *BBCodeNode) {
+	origin, ok := r.origins[tag]
+	if !ok {
+		origin = tag
+		r.chains[origin] = []*BBCodeNode{tag}
+	}
+	r.origins[reopened] = origin
+	r.chains[origin] = append(r.chains[origin], reopened)
+	r.copies = append(r.copies, reopened)
+}
+
+// finish undoes the repair of tags that are never closed in the source, so
+// they are treated like any other unclosed tag, and removes copies that
+// ended up empty. The closing tag of a removed copy is moved back to the tag
+// it continues, so its source isn't lost.
+func (r *repairer) finish() {
+	for _, chain := range r.chains {
+		if chain[len(chain)-1].ClosingTag != nil {
+			continue
+		}
+		for _, node := range chain {
+			node.ClosingTag = nil
+		}
+	}
+	for i := len(r.copies) - 1; i >= 0; i-- {
+		node := r.copies[i]
+		if len(node.Children) != 0 {
+			continue
+		}
+		chain := r.chains[r.origins[node]]
+		for j := 1; j < len(chain); j++ {
+			if chain[j] == node && node.ClosingTag != nil {
+				chain[j-1].ClosingTag = node.ClosingTag
+			}
+		}
+		node.Remove()
+	}
+}
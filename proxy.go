@@ -0,0 +1,82 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/url"
+	"strings"
+)
+
+// ImageProxy rewrites image URLs so they are fetched through a Camo-style
+// proxy instead of being hot-linked. Proxied URLs have the form
+// BaseURL/<hmac>/<hex-url>, where hmac is the hex encoded HMAC-SHA1 of the
+// original URL under Key, matching the format used by Camo and go-camo.
+type ImageProxy struct {
+	BaseURL string
+	Key     []byte
+}
+
+// NewImageProxy creates an ImageProxy that signs URLs with key.
+func NewImageProxy(baseURL string, key []byte) *ImageProxy {
+	return &ImageProxy{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Key:     key,
+	}
+}
+
+func (p *ImageProxy) sign(raw string) []byte {
+	mac := hmac.New(sha1.New, p.Key)
+	mac.Write([]byte(raw))
+	return mac.Sum(nil)
+}
+
+// Rewrite returns the proxied form of raw. Only absolute http and https
+// URLs are rewritten, along with protocol-relative URLs such as
+// //example.com/a.png, which are read as https. Anything else is returned
+// unchanged.
+func (p *ImageProxy) Rewrite(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	if u.Scheme == "" {
+		u.Scheme = "https"
+		raw = u.String()
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return raw
+	}
+	return strings.TrimRight(p.BaseURL, "/") + "/" + hex.EncodeToString(p.sign(raw)) + "/" + hex.EncodeToString([]byte(raw))
+}
+
+// Verify checks the digest and hex encoded URL of a proxy request, and
+// returns the original URL if the signature is valid.
+func (p *ImageProxy) Verify(digest, encodedURL string) (string, bool) {
+	mac, err := hex.DecodeString(digest)
+	if err != nil {
+		return "", false
+	}
+	raw, err := hex.DecodeString(encodedURL)
+	if err != nil {
+		return "", false
+	}
+	if !hmac.Equal(mac, p.sign(string(raw))) {
+		return "", false
+	}
+	return string(raw), true
+}
+
+// VerifyPath is like Verify, but takes the path of a proxy request ending
+// in /<hmac>/<hex-url>.
+func (p *ImageProxy) VerifyPath(path string) (string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 {
+		return "", false
+	}
+	return p.Verify(parts[len(parts)-2], parts[len(parts)-1])
+}
@@ -0,0 +1,55 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"strings"
+	"testing"
+)
+
+var proxyTests = map[string]string{
+	`[img]http://example.com/a.png[/img]`:   `<img src="https://proxy.example/ecc8ab72d767428b6effbb61fe613f3100901b75/687474703a2f2f6578616d706c652e636f6d2f612e706e67">`,
+	`[img=https://example.com/a.png][/img]`: `<img src="https://proxy.example/7acabb99fc86089c8ca6c0c2410405f57d79aba0/68747470733a2f2f6578616d706c652e636f6d2f612e706e67">`,
+	`[img]//evil.example/a.png[/img]`:       `<img src="https://proxy.example/19476275a6be2d687d112016859ae5a81615eb02/68747470733a2f2f6576696c2e6578616d706c652f612e706e67">`,
+	`[img]/local.png[/img]`:                 `<img src="/local.png">`,
+	`[img]javascript:alert(1)[/img]`:        `<img src="javascript:alert(1)">`,
+	`[url=http://example.com]link[/url]`:    `<a href="http://example.com">link</a>`,
+}
+
+func TestImageProxy(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.ImageProxy = NewImageProxy("https://proxy.example/", []byte("secret"))
+	for in, out := range proxyTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
+
+func TestImageProxyVerify(t *testing.T) {
+	p := NewImageProxy("https://proxy.example/camo", []byte("secret"))
+	raw := "http://example.com/a.png?x=1&y=2"
+	proxied := p.Rewrite(raw)
+	path := strings.TrimPrefix(proxied, "https://proxy.example")
+	if result, ok := p.VerifyPath(path); !ok || result != raw {
+		t.Errorf("Failed to verify %s.\nExpected: %s, got: %s (%v)\n", path, raw, result, ok)
+	}
+
+	parts := strings.Split(path, "/")
+	digest, encoded := parts[len(parts)-2], parts[len(parts)-1]
+	if _, ok := p.Verify(digest, encoded+"00"); ok {
+		t.Errorf("Verified tampered url for %s\n", path)
+	}
+	if _, ok := p.Verify("00"+digest[2:], encoded); ok {
+		t.Errorf("Verified tampered digest for %s\n", path)
+	}
+	if _, ok := NewImageProxy("https://proxy.example", []byte("other")).Verify(digest, encoded); ok {
+		t.Errorf("Verified %s with the wrong key\n", path)
+	}
+	if _, ok := p.VerifyPath("/nothex/zz"); ok {
+		t.Errorf("Verified invalid path\n")
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// QuoteSource describes where a [quote] came from, as written in
+// [quote name=x post=123 time=1700000000].
+type QuoteSource struct {
+	Author string
+	Post   string
+	// Time is the time of the quoted post, or zero if it isn't known. The
+	// time argument is read as Unix seconds or as RFC 3339.
+	Time time.Time
+	// URL is the link to the quoted post, from the compiler's QuoteResolver.
+	URL string
+	// FormattedTime is Time formatted for display.
+	FormattedTime string
+	// Depth is the number of quotes around the quote.
+	Depth int
+}
+
+// QuoteResolver links quotes to the posts they came from, and formats their
+// times, for the viewer described by ctx.
+type QuoteResolver interface {
+	PostURL(ctx context.Context, source QuoteSource) (string, bool)
+	FormatTime(ctx context.Context, t time.Time) string
+}
+
+// QuoteCiteFunc builds the <cite> element of a quote.
+type QuoteCiteFunc func(node *BBCodeNode, source QuoteSource) *HTMLTag
+
+// DefaultQuoteCite outputs the author and time of a quote, linking the
+// author to the quoted post if its URL is known. Its text comes from the
+// quote and quote.said messages.
+func DefaultQuoteCite(node *BBCodeNode, source QuoteSource) *HTMLTag {
+	var c Compiler
+	if node.Compiler != nil {
+		c = *node.Compiler
+	}
+	cite := NewHTMLTag("")
+	cite.Name = "cite"
+	before, after := c.Message("quote"), ""
+	author := NewHTMLTag("")
+	if source.Author != "" {
+		var ok bool
+		if before, after, ok = expandMessage(c.Message("quote.said"), "author"); ok {
+			author = NewHTMLTag(source.Author)
+		}
+	}
+	if source.URL != "" {
+		// Link the author, or the whole citation if it doesn't name one.
+		link := NewHTMLTag("")
+		link.Name = "a"
+		link.Attrs["href"] = ValidURL(source.URL)
+		if author.Value == "" {
+			author, before = NewHTMLTag(before), ""
+		}
+		author = link.AppendChild(author)
+	}
+	for _, part := range []*HTMLTag{NewHTMLTag(before), author, NewHTMLTag(after)} {
+		if part.Value != "" || part.Name != "" {
+			cite.AppendChild(part)
+		}
+	}
+	if !source.Time.IsZero() {
+		when := NewHTMLTag("")
+		when.Name = "time"
+		when.Attrs["datetime"] = source.Time.UTC().Format(time.RFC3339)
+		cite.AppendChild(NewHTMLTag(" "))
+		cite.AppendChild(when.AppendChild(NewHTMLTag(source.FormattedTime)))
+	}
+	if len(cite.Children) == 0 {
+		cite.AppendChild(NewHTMLTag(""))
+	}
+	return cite
+}
+
+// parseQuoteTime reads the time argument of a quote.
+func parseQuoteTime(value string) time.Time {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds > 0 {
+		return time.Unix(seconds, 0)
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// quoteDepth returns the number of rendered quotes around node.
+func (c Compiler) quoteDepth(node *BBCodeNode) int {
+	depth := 0
+	for n := node.Parent; n != nil; n = n.Parent {
+		if tagName(n) == "quote" && (n.ClosingTag != nil || c.AutoCloseTags) {
+			depth++
+		}
+	}
+	return depth
+}
+
+// compileQuote compiles a [quote] tag. The first quote nested deeper than
+// MaxQuoteDepth is collapsed into a <details> element.
+func compileQuote(node *BBCodeNode) (*HTMLTag, bool) {
+	var c Compiler
+	if node.Compiler != nil {
+		c = *node.Compiler
+	}
+	in := node.GetOpeningTag()
+	source := QuoteSource{
+		Author: in.Args["name"],
+		Post:   in.Args["post"],
+		Time:   parseQuoteTime(in.Args["time"]),
+		Depth:  c.quoteDepth(node),
+	}
+	if source.Author == "" {
+		source.Author = in.Value
+	}
+	if c.QuoteResolver != nil {
+		if source.Post != "" {
+			source.URL, _ = c.QuoteResolver.PostURL(c.Context(), source)
+		}
+		if !source.Time.IsZero() {
+			source.FormattedTime = c.QuoteResolver.FormatTime(c.Context(), source.Time)
+		}
+	} else if !source.Time.IsZero() {
+		source.FormattedTime = source.Time.UTC().Format(c.Message("quote.time"))
+	}
+
+	cite := c.QuoteCite
+	if cite == nil {
+		cite = DefaultQuoteCite
+	}
+	out := NewHTMLTag("")
+	out.Name = "blockquote"
+	if tag := cite(node, source); tag != nil {
+		out.AppendChild(tag)
+	}
+	if c.MaxQuoteDepth <= 0 || source.Depth != c.MaxQuoteDepth {
+		return out, true
+	}
+
+	details := NewHTMLTag("")
+	details.Name = "details"
+	details.Attrs["class"] = "quote-more"
+	summary := NewHTMLTag("")
+	summary.Name = "summary"
+	details.AppendChild(summary.AppendChild(NewHTMLTag(c.Message("quote.more"))))
+	if len(node.Children) == 0 {
+		out.AppendChild(NewHTMLTag(""))
+	} else {
+		c.compileChildren(node, out)
+	}
+	return details.AppendChild(out), false
+}
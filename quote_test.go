@@ -0,0 +1,137 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var quoteTests = map[string]string{
+	"[quote]hi[/quote]":                                        `<blockquote><cite>Quote</cite>hi</blockquote>`,
+	"[quote=Bob]hi[/quote]":                                    `<blockquote><cite>Bob said:</cite>hi</blockquote>`,
+	"[quote name=Bob time=1700000000]hi[/quote]":               `<blockquote><cite>Bob said: <time datetime="2023-11-14T22:13:20Z">2023-11-14 22:13</time></cite>hi</blockquote>`,
+	"[quote name=Bob time=2023-11-14T22:13:20+01:00]x[/quote]": `<blockquote><cite>Bob said: <time datetime="2023-11-14T21:13:20Z">2023-11-14 21:13</time></cite>x</blockquote>`,
+	"[quote name=Bob time=yesterday]hi[/quote]":                `<blockquote><cite>Bob said:</cite>hi</blockquote>`,
+	"[quote name=Bob post=12]hi[/quote]":                       `<blockquote><cite>Bob said:</cite>hi</blockquote>`,
+}
+
+var resolvedQuoteTests = map[string]string{
+	"[quote name=Bob post=12]hi[/quote]":                 `<blockquote><cite><a href="/posts/12">Bob</a> said:</cite>hi</blockquote>`,
+	"[quote post=12]hi[/quote]":                          `<blockquote><cite><a href="/posts/12">Quote</a></cite>hi</blockquote>`,
+	"[quote name=Bob post=99]hi[/quote]":                 `<blockquote><cite>Bob said:</cite>hi</blockquote>`,
+	"[quote name=Bob post=12 time=1700000000]hi[/quote]": `<blockquote><cite><a href="/posts/12">Bob</a> said: <time datetime="2023-11-14T22:13:20Z">Nov 14, 2023</time></cite>hi</blockquote>`,
+}
+
+var nestedQuoteTests = map[string]string{
+	"[quote=A]a[/quote]":                                                    `<blockquote><cite>A said:</cite>a</blockquote>`,
+	"[quote=A][quote=B]b[/quote]a[/quote]":                                  `<blockquote><cite>A said:</cite><blockquote><cite>B said:</cite>b</blockquote>a</blockquote>`,
+	"[quote=A][quote=B][quote=C]c[/quote]b[/quote]a[/quote]":                `<blockquote><cite>A said:</cite><blockquote><cite>B said:</cite><details class="quote-more"><summary>Show more</summary><blockquote><cite>C said:</cite>c</blockquote></details>b</blockquote>a</blockquote>`,
+	"[quote=A][quote=B][quote=C][quote=D]d[/quote][/quote][/quote][/quote]": `<blockquote><cite>A said:</cite><blockquote><cite>B said:</cite><details class="quote-more"><summary>Show more</summary><blockquote><cite>C said:</cite><blockquote><cite>D said:</cite>d</blockquote></blockquote></details></blockquote></blockquote>`,
+	"[quote=A][quote=B][quote=C][/quote][/quote][/quote]":                   `<blockquote><cite>A said:</cite><blockquote><cite>B said:</cite><details class="quote-more"><summary>Show more</summary><blockquote><cite>C said:</cite></blockquote></details></blockquote></blockquote>`,
+	"[quote=A][quote=B][quote=C]c[/quote][/quote]":                          `[quote=A]<blockquote><cite>B said:</cite><blockquote><cite>C said:</cite>c</blockquote></blockquote>`,
+}
+
+type testQuoteResolver struct{}
+
+func (testQuoteResolver) PostURL(ctx context.Context, source QuoteSource) (string, bool) {
+	if source.Post != "12" {
+		return "", false
+	}
+	return "/posts/" + source.Post, true
+}
+
+func (testQuoteResolver) FormatTime(ctx context.Context, t time.Time) string {
+	return t.UTC().Format("Jan 2, 2006")
+}
+
+func TestQuotes(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	for in, out := range quoteTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+
+	c.QuoteResolver = testQuoteResolver{}
+	for in, out := range resolvedQuoteTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
+
+func TestNestedQuotes(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	c.MaxQuoteDepth = 2
+	for in, out := range nestedQuoteTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
+
+func TestQuoteCite(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	c.QuoteCite = func(node *BBCodeNode, source QuoteSource) *HTMLTag {
+		if source.Author == "" {
+			return nil
+		}
+		cite := NewHTMLTag("")
+		cite.Name = "footer"
+		cite.Attrs["data-depth"] = string(rune('0' + source.Depth))
+		return cite.AppendChild(NewHTMLTag("— " + source.Author))
+	}
+	tests := map[string]string{
+		"[quote]hi[/quote]":                   `<blockquote>hi</blockquote>`,
+		"[quote=A][quote=B]b[/quote][/quote]": `<blockquote><footer data-depth="0">— A</footer><blockquote><footer data-depth="1">— B</footer>b</blockquote></blockquote>`,
+	}
+	for in, out := range tests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
+
+type zoneKey struct{}
+
+type zoneQuoteResolver struct{ testQuoteResolver }
+
+func (zoneQuoteResolver) FormatTime(ctx context.Context, t time.Time) string {
+	zone, ok := ctx.Value(zoneKey{}).(*time.Location)
+	if !ok {
+		zone = time.UTC
+	}
+	return t.In(zone).Format("15:04 MST")
+}
+
+func TestDocumentQuotes(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	c.QuoteResolver = zoneQuoteResolver{}
+	doc := c.Prepare("[quote name=Bob time=1700000000]hi[/quote]")
+	newYork := context.WithValue(context.Background(), zoneKey{}, time.FixedZone("EST", -5*60*60))
+	tests := []struct {
+		ctx context.Context
+		out string
+	}{
+		{context.Background(), `<blockquote><cite>Bob said: <time datetime="2023-11-14T22:13:20Z">22:13 UTC</time></cite>hi</blockquote>`},
+		{newYork, `<blockquote><cite>Bob said: <time datetime="2023-11-14T22:13:20Z">17:13 EST</time></cite>hi</blockquote>`},
+		{context.Background(), `<blockquote><cite>Bob said: <time datetime="2023-11-14T22:13:20Z">22:13 UTC</time></cite>hi</blockquote>`},
+	}
+	for _, test := range tests {
+		if result := doc.Render(test.ctx); result != test.out {
+			t.Errorf("Failed to render document.\nExpected: %s, got: %s\n", test.out, result)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SizeUnit is a unit accepted by the size tag.
+type SizeUnit string
+
+const (
+	SizeScale   SizeUnit = ""   // The legacy HTML font size scale, 1 to 7.
+	SizePercent SizeUnit = "%"  // Percent of the surrounding font size, as used by phpBB.
+	SizePixels  SizeUnit = "px" // CSS pixels.
+	SizePoints  SizeUnit = "pt" // Typographic points.
+)
+
+// SizePolicy controls how the value of a [size] tag is interpreted and
+// output.
+type SizePolicy struct {
+	// Unit is the unit of values written without one, such as [size=3].
+	Unit SizeUnit
+	// Limits holds the minimum and maximum size for each accepted unit.
+	// Sizes are clamped to these limits, and units without limits are
+	// rejected.
+	Limits map[SizeUnit][2]int
+	// InlineStyle outputs a font-size style instead of a class name. It is
+	// ignored when the compiler has ClassOutput enabled.
+	InlineStyle bool
+}
+
+// DefaultSizePolicy treats bare numbers as the legacy 1 to 7 scale, and
+// accepts percent, pixel and point sizes within readable bounds.
+var DefaultSizePolicy = SizePolicy{
+	Unit: SizeScale,
+	Limits: map[SizeUnit][2]int{
+		SizeScale:   {1, 7},
+		SizePercent: {50, 200},
+		SizePixels:  {8, 36},
+		SizePoints:  {6, 28},
+	},
+}
+
+// clone returns a copy of the policy that doesn't share its Limits.
+func (p SizePolicy) clone() SizePolicy {
+	limits := make(map[SizeUnit][2]int, len(p.Limits))
+	for unit, limit := range p.Limits {
+		limits[unit] = limit
+	}
+	p.Limits = limits
+	return p
+}
+
+var scaleFontSizes = []string{"x-small", "small", "medium", "large", "x-large", "xx-large", "xxx-large"}
+
+// Parse interprets a size value, returning the clamped size rounded to a
+// whole number and its unit.
+func (p SizePolicy) Parse(value string) (int, SizeUnit, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	unit := p.Unit
+	for _, u := range []SizeUnit{SizePercent, SizePixels, SizePoints} {
+		if strings.HasSuffix(value, string(u)) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, string(u)))
+			unit = u
+			break
+		}
+	}
+	limits, ok := p.Limits[unit]
+	if !ok {
+		return 0, unit, false
+	}
+	f, ok := parseNumber(value, math.Inf(-1), math.Inf(1))
+	if !ok {
+		return 0, unit, false
+	}
+	size := int(math.Min(math.Max(math.Floor(f+0.5), float64(limits[0])), float64(limits[1])))
+	return size, unit, true
+}
+
+// Class returns the class name for a parsed size. Sizes on the legacy scale
+// keep the sizeN form, other units are named like size-12px or size-150pct.
+func (p SizePolicy) Class(size int, unit SizeUnit) string {
+	switch unit {
+	case SizeScale:
+		return "size" + strconv.Itoa(size)
+	case SizePercent:
+		return "size-" + strconv.Itoa(size) + "pct"
+	}
+	return "size-" + strconv.Itoa(size) + string(unit)
+}
+
+// Style returns the font-size declaration for a parsed size.
+func (p SizePolicy) Style(size int, unit SizeUnit) string {
+	if unit == SizeScale {
+		if size < 1 || size > len(scaleFontSizes) {
+			return ""
+		}
+		return "font-size: " + scaleFontSizes[size-1] + ";"
+	}
+	return "font-size: " + strconv.Itoa(size) + string(unit) + ";"
+}
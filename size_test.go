@@ -0,0 +1,91 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import "testing"
+
+var sizeTests = map[string]string{
+	"[size=6]hello[/size]":       `<span class="size6">hello</span>`,
+	"[size=-3]hello[/size]":      `<span class="size1">hello</span>`,
+	"[size=99999]hello[/size]":   `<span class="size7">hello</span>`,
+	"[size=2.6]hello[/size]":     `<span class="size3">hello</span>`,
+	"[size=12px]hello[/size]":    `<span class="size-12px">hello</span>`,
+	"[size=1px]hello[/size]":     `<span class="size-8px">hello</span>`,
+	"[size=150%]hello[/size]":    `<span class="size-150pct">hello</span>`,
+	"[size=1000%]hello[/size]":   `<span class="size-200pct">hello</span>`,
+	"[size=10PT]hello[/size]":    `<span class="size-10pt">hello</span>`,
+	"[size=2em]hello[/size]":     `<span>hello</span>`,
+	"[size=big]hello[/size]":     `<span>hello</span>`,
+	"[size=1e9]hello[/size]":     `<span>hello</span>`,
+	"[size]hello[/size]":         `<span>hello</span>`,
+	`[size="12 px"]hello[/size]`: `<span class="size-12px">hello</span>`,
+}
+
+func TestSize(t *testing.T) {
+	c := NewCompiler(false, false)
+	for in, out := range sizeTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
+
+var sizeInlineTests = map[string]string{
+	"[size=150]hello[/size]":  `<span style="font-size: 150%;">hello</span>`,
+	"[size=20]hello[/size]":   `<span style="font-size: 50%;">hello</span>`,
+	"[size=14px]hello[/size]": `<span style="font-size: 14px;">hello</span>`,
+	"[size=3]hello[/size]":    `<span style="font-size: 50%;">hello</span>`,
+	"[size=10pt]hello[/size]": `<span>hello</span>`,
+}
+
+func TestSizeInline(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SizePolicy = SizePolicy{
+		Unit: SizePercent,
+		Limits: map[SizeUnit][2]int{
+			SizePercent: {50, 200},
+			SizePixels:  {8, 36},
+		},
+		InlineStyle: true,
+	}
+	for in, out := range sizeInlineTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+
+	c.ClassOutput = true
+	in, out := "[size=150]hello[/size]", `<span class="bb-size-150pct">hello</span>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+}
+
+func TestSizeScaleStyle(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SizePolicy.InlineStyle = true
+	in, out := "[size=5]hello[/size]", `<span style="font-size: x-large;">hello</span>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+}
+
+func TestSizeLimitsNotShared(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SizePolicy.Limits[SizePixels] = [2]int{8, 72}
+	if limits := DefaultSizePolicy.Limits[SizePixels]; limits[1] != 36 {
+		t.Errorf("Changing a compiler's size limits changed DefaultSizePolicy: %v\n", limits)
+	}
+	in, out := "[size=60px]hello[/size]", `<span class="size-36px">hello</span>`
+	if result := NewCompiler(false, false).Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+	in, out = "[size=60px]hello[/size]", `<span class="size-60px">hello</span>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+}
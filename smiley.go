@@ -0,0 +1,102 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Smiley is the replacement for a smiley code. If Emoji is set the code is
+// replaced with that text, otherwise with an image loaded from Src.
+type Smiley struct {
+	Src    string
+	Alt    string
+	Width  int
+	Height int
+	Emoji  string
+}
+
+// replaceSmileys splits text into text and smileys. A code only matches at
+// the start of the text or after whitespace, and must be followed by the
+// end of the text, whitespace or punctuation.
+func (c Compiler) replaceSmileys(text string) []*HTMLTag {
+	codes := make([]string, 0, len(c.Smileys))
+	for code := range c.Smileys {
+		if code != "" {
+			codes = append(codes, code)
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if len(codes[i]) != len(codes[j]) {
+			return len(codes[i]) > len(codes[j])
+		}
+		return codes[i] < codes[j]
+	})
+
+	var out []*HTMLTag
+	start, prev := 0, ' '
+	for i := 0; i < len(text); {
+		if unicode.IsSpace(prev) {
+			if code := matchSmiley(text[i:], codes); code != "" {
+				if i > start {
+					out = append(out, NewHTMLTag(text[start:i]))
+				}
+				out = append(out, c.Smileys[code].tag(code))
+				i += len(code)
+				start, prev = i, 'x'
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		prev = r
+		i += size
+	}
+	if start < len(text) {
+		out = append(out, NewHTMLTag(text[start:]))
+	}
+	return out
+}
+
+func matchSmiley(text string, codes []string) string {
+	for _, code := range codes {
+		if !strings.HasPrefix(text, code) {
+			continue
+		}
+		rest := text[len(code):]
+		if len(rest) == 0 {
+			return code
+		}
+		if r, _ := utf8.DecodeRuneInString(rest); unicode.IsSpace(r) || strings.ContainsRune(".,!?;", r) {
+			return code
+		}
+	}
+	return ""
+}
+
+func (s Smiley) tag(code string) *HTMLTag {
+	if s.Emoji != "" {
+		return NewHTMLTag(s.Emoji)
+	}
+	out := NewHTMLTag("")
+	out.Name = "img"
+	out.Attrs["class"] = "smiley"
+	out.Attrs["src"] = ValidURL(s.Src)
+	out.Attrs["alt"] = code
+	if s.Alt != "" {
+		out.Attrs["alt"] = s.Alt
+	}
+	out.Attrs["title"] = out.Attrs["alt"]
+	if s.Width > 0 {
+		out.Attrs["width"] = strconv.Itoa(s.Width)
+	}
+	if s.Height > 0 {
+		out.Attrs["height"] = strconv.Itoa(s.Height)
+	}
+	return out
+}
@@ -0,0 +1,53 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import "testing"
+
+var smileyTests = map[string]string{
+	":)":                               `<img alt=":)" class="smiley" src="/s/smile.png" title=":)">`,
+	"hi :) there":                      `hi <img alt=":)" class="smiley" src="/s/smile.png" title=":)"> there`,
+	"done :).":                         `done <img alt=":)" class="smiley" src="/s/smile.png" title=":)">.`,
+	"a:) :)b":                          `a:) :)b`,
+	":lol: :thumbsup:":                 `<img alt="laughing" class="smiley" height="15" src="/s/lol.gif" title="laughing" width="15"> 👍`,
+	":-) :-)) :)":                      `🙂 😀 <img alt=":)" class="smiley" src="/s/smile.png" title=":)">`,
+	"see http://example.com/ :/":       `see http://example.com/ 🫤`,
+	"x\n:)":                            `x<br><img alt=":)" class="smiley" src="/s/smile.png" title=":)">`,
+	"[b]:)[/b]":                        `<b><img alt=":)" class="smiley" src="/s/smile.png" title=":)"></b>`,
+	"[code]:)[/code]":                  `<pre>:)</pre>`,
+	"[url=http://example.com]:)[/url]": `<a href="http://example.com">:)</a>`,
+	"[quote=:)]hi[/quote]":             `<blockquote><cite>:) said:</cite>hi</blockquote>`,
+	"<:)>":                             `&lt;:)&gt;`,
+}
+
+func TestSmileys(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	c.Smileys = map[string]Smiley{
+		":)":         {Src: "/s/smile.png"},
+		":lol:":      {Src: "/s/lol.gif", Alt: "laughing", Width: 15, Height: 15},
+		":thumbsup:": {Emoji: "👍"},
+		":-)":        {Emoji: "🙂"},
+		":-))":       {Emoji: "😀"},
+		":/":         {Emoji: "🫤"},
+	}
+	for in, out := range smileyTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
+
+func TestSmileysAutoLink(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.AutoLink = true
+	c.Smileys = map[string]Smiley{":/": {Emoji: "🫤"}, ":)": {Emoji: "🙂"}}
+	in := "http://example.com/:/ :) :/"
+	out := `<a href="http://example.com/:/">http://example.com/:/</a> 🙂 🫤`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+}
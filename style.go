@@ -0,0 +1,136 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"sort"
+	"strings"
+)
+
+// ColorPalette maps arbitrary colors onto a fixed set of class names, for
+// compilers with ClassOutput enabled.
+type ColorPalette struct {
+	// Colors maps class names to CSS color values.
+	Colors map[string]string
+	// Fallback is the class name used for colors that aren't in the palette.
+	// If empty, the nearest palette color is used instead.
+	Fallback string
+}
+
+// DefaultColorPalette contains the 16 basic HTML colors.
+var DefaultColorPalette = ColorPalette{
+	Colors: map[string]string{
+		"black":   "#000000",
+		"silver":  "#c0c0c0",
+		"gray":    "#808080",
+		"white":   "#ffffff",
+		"maroon":  "#800000",
+		"red":     "#ff0000",
+		"purple":  "#800080",
+		"fuchsia": "#ff00ff",
+		"green":   "#008000",
+		"lime":    "#00ff00",
+		"olive":   "#808000",
+		"yellow":  "#ffff00",
+		"navy":    "#000080",
+		"blue":    "#0000ff",
+		"teal":    "#008080",
+		"aqua":    "#00ffff",
+	},
+}
+
+// Class returns the palette class name for color, which may be a palette
+// name or any color accepted by ParseColor.
+func (p ColorPalette) Class(color string) (string, bool) {
+	name := strings.ToLower(strings.TrimSpace(color))
+	if _, ok := p.Colors[name]; ok {
+		return name, true
+	}
+	c, ok := ParseColor(color)
+	if !ok {
+		return p.Fallback, p.Fallback != ""
+	}
+
+	names := p.names()
+	for _, name := range names {
+		if pc, ok := ParseColor(p.Colors[name]); ok && pc.R == c.R && pc.G == c.G && pc.B == c.B {
+			return name, true
+		}
+	}
+	if p.Fallback != "" {
+		return p.Fallback, true
+	}
+
+	best, bestDist := "", -1
+	for _, name := range names {
+		pc, ok := ParseColor(p.Colors[name])
+		if !ok {
+			continue
+		}
+		dr, dg, db := int(c.R)-int(pc.R), int(c.G)-int(pc.G), int(c.B)-int(pc.B)
+		dist := dr*dr + dg*dg + db*db
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = name, dist
+		}
+	}
+	return best, best != ""
+}
+
+// clone returns a copy of the palette that doesn't share its Colors.
+func (p ColorPalette) clone() ColorPalette {
+	colors := make(map[string]string, len(p.Colors))
+	for name, value := range p.Colors {
+		colors[name] = value
+	}
+	p.Colors = colors
+	return p
+}
+
+func (p ColorPalette) names() []string {
+	names := make([]string, 0, len(p.Colors))
+	for name := range p.Colors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sizeClassPrefix returns the prefix of size class names, which is
+// ClassPrefix when ClassOutput is enabled. Without it, sizes keep their
+// unprefixed sizeN class names.
+func (c Compiler) sizeClassPrefix() string {
+	if c.ClassOutput {
+		return c.ClassPrefix
+	}
+	return ""
+}
+
+// Stylesheet returns the CSS rules for the class names output by the
+// compiler, including the colors and center tag when ClassOutput is enabled.
+// There is a rule for every size within SizePolicy.Limits, which comes to
+// 210 rules with DefaultSizePolicy; narrower limits give a smaller
+// stylesheet.
+func (c Compiler) Stylesheet() string {
+	var css strings.Builder
+	rule := func(class, declaration string) {
+		css.WriteString("." + class + " { " + declaration + " }\n")
+	}
+	rule(c.ClassPrefix+"center", "text-align: center;")
+	for _, name := range c.Palette.names() {
+		rule(c.ClassPrefix+"color-"+name, "color: "+c.Palette.Colors[name]+";")
+	}
+	for _, unit := range []SizeUnit{SizeScale, SizePercent, SizePixels, SizePoints} {
+		limits, ok := c.SizePolicy.Limits[unit]
+		if !ok {
+			continue
+		}
+		for size := limits[0]; size <= limits[1]; size++ {
+			if style := c.SizePolicy.Style(size, unit); style != "" {
+				rule(c.sizeClassPrefix()+c.SizePolicy.Class(size, unit), style)
+			}
+		}
+	}
+	return css.String()
+}
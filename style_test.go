@@ -0,0 +1,98 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"strings"
+	"testing"
+)
+
+var classOutputTests = map[string]string{
+	"[center]hello[/center]":        `<div class="bb-center">hello</div>`,
+	"[color=red]hello[/color]":      `<span class="bb-color-red">hello</span>`,
+	"[color=RED]hello[/color]":      `<span class="bb-color-red">hello</span>`,
+	"[color=#00f]hello[/color]":     `<span class="bb-color-blue">hello</span>`,
+	"[color=#00BFFF]hello[/color]":  `<span class="bb-color-aqua">hello</span>`,
+	"[color=#7f0000]hello[/color]":  `<span class="bb-color-maroon">hello</span>`,
+	"[color=salmon]hello[/color]":   `<span class="bb-color-silver">hello</span>`,
+	"[color=url(x)]hello[/color]":   `<span>hello</span>`,
+	"[color]hello[/color]":          `<span>hello</span>`,
+	"[center][color=#fff]x[/color]": `<div class="bb-center"><span class="bb-color-white">x</span></div>`,
+}
+
+func TestClassOutput(t *testing.T) {
+	c := NewCompiler(true, false)
+	c.ClassOutput = true
+	for in, out := range classOutputTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
+
+func TestPaletteNotShared(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.ClassOutput = true
+	c.Palette.Colors["orange"] = "#ffa500"
+	if _, ok := DefaultColorPalette.Colors["orange"]; ok {
+		t.Errorf("Changing a compiler's palette changed DefaultColorPalette\n")
+	}
+	if result := NewCompiler(false, false).Palette.Colors["orange"]; result != "" {
+		t.Errorf("Changing a compiler's palette changed another compiler: %s\n", result)
+	}
+	if result := c.Compile("[color=#ffa500]x[/color]"); result != `<span class="bb-color-orange">x</span>` {
+		t.Errorf("Failed to use added palette color: %s\n", result)
+	}
+}
+
+var paletteFallbackTests = map[string]string{
+	"red":     "red",
+	"#ff0000": "red",
+	"#fe0000": "custom",
+	"purple":  "custom",
+	"url(x)":  "custom",
+}
+
+func TestColorPaletteFallback(t *testing.T) {
+	p := ColorPalette{
+		Colors:   map[string]string{"red": "#ff0000", "blue": "#0000ff"},
+		Fallback: "custom",
+	}
+	for in, out := range paletteFallbackTests {
+		result, ok := p.Class(in)
+		if !ok || result != out {
+			t.Errorf("Failed to map %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
+
+func TestStylesheet(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.ClassPrefix = "x-"
+	c.Palette = ColorPalette{Colors: map[string]string{"red": "#ff0000", "blue": "#0000ff"}}
+	c.SizePolicy = SizePolicy{Limits: map[SizeUnit][2]int{SizeScale: {1, 2}, SizePixels: {10, 11}}}
+	expected := strings.Join([]string{
+		".x-center { text-align: center; }",
+		".x-color-blue { color: #0000ff; }",
+		".x-color-red { color: #ff0000; }",
+		".size1 { font-size: x-small; }",
+		".size2 { font-size: small; }",
+		".size-10px { font-size: 10px; }",
+		".size-11px { font-size: 11px; }",
+		"",
+	}, "\n")
+	if result := c.Stylesheet(); result != expected {
+		t.Errorf("Failed to generate stylesheet.\nExpected: %s, got: %s\n", expected, result)
+	}
+	c.ClassOutput = true
+	expected = strings.Replace(expected, ".size", ".x-size", -1)
+	if result := c.Stylesheet(); result != expected {
+		t.Errorf("Failed to generate stylesheet.\nExpected: %s, got: %s\n", expected, result)
+	}
+	if result := c.Compile("[size=2]a[/size]"); result != `<span class="x-size2">a</span>` {
+		t.Errorf("Failed to prefix size class: %s\n", result)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MaxTableSpan is the largest colspan or rowspan accepted on a table cell.
+// Larger spans are reduced to it.
+const MaxTableSpan = 100
+
+// tagName returns the name of node if it is an opening tag, or "".
+func tagName(node *BBCodeNode) string {
+	if node.ID != OPENING_TAG {
+		return ""
+	}
+	return node.Value.(BBOpeningTag).Name
+}
+
+// isWhitespace reports whether node is a text node with only whitespace.
+func isWhitespace(node *BBCodeNode) bool {
+	return node.ID == TEXT && strings.TrimSpace(node.Value.(string)) == "" && len(node.Children) == 0
+}
+
+// compileTable compiles a [table] tag with its rows and cells. Whitespace
+// between rows and cells is dropped, and other content outside of cells is
+// moved in front of the table. The first row is a header row when the tag
+// has a header argument.
+func compileTable(node *BBCodeNode) (*HTMLTag, bool) {
+	c := node.Compiler
+	out := NewHTMLTag("")
+	table := NewHTMLTag("")
+	table.Name = "table"
+	stray := func(child *BBCodeNode) {
+		if !isWhitespace(child) {
+			out.AppendChild(c.CompileTree(child))
+		}
+	}
+
+	// Rows are compiled in document order along with the stray content, so
+	// that it is moved in front of the table in the order it was written.
+	_, header := node.GetOpeningTag().Args["header"]
+	var rows []*HTMLTag
+	for _, child := range node.Children {
+		if tagName(child) == "tr" {
+			rows = append(rows, compileTableRow(c, child, header && len(rows) == 0, stray))
+		} else {
+			stray(child)
+		}
+	}
+
+	body := table
+	if header && len(rows) > 0 {
+		head := NewHTMLTag("")
+		head.Name = "thead"
+		table.AppendChild(head.AppendChild(rows[0]))
+		rows = rows[1:]
+		body = NewHTMLTag("")
+		body.Name = "tbody"
+		table.AppendChild(body)
+	}
+	for _, row := range rows {
+		body.AppendChild(row)
+	}
+	if len(body.Children) == 0 {
+		body.AppendChild(NewHTMLTag(""))
+	}
+	return out.AppendChild(table), false
+}
+
+// compileTableRow compiles a [tr] tag, passing content outside of cells to
+// stray.
+func compileTableRow(c *Compiler, node *BBCodeNode, header bool, stray func(*BBCodeNode)) *HTMLTag {
+	out := NewHTMLTag("")
+	out.Name = "tr"
+	for _, child := range node.Children {
+		name := tagName(child)
+		if name != "td" && name != "th" {
+			stray(child)
+			continue
+		}
+		cell := NewHTMLTag("")
+		cell.Name = name
+		if header {
+			cell.Name = "th"
+		}
+		args := child.Value.(BBOpeningTag).Args
+		for _, attr := range []string{"colspan", "rowspan"} {
+			if span, err := strconv.Atoi(args[attr]); err == nil && span > 1 {
+				if span > MaxTableSpan {
+					span = MaxTableSpan
+				}
+				cell.Attrs[attr] = strconv.Itoa(span)
+			}
+		}
+		if len(child.Children) == 0 {
+			cell.AppendChild(NewHTMLTag(""))
+		} else {
+			c.compileChildren(child, cell)
+		}
+		out.AppendChild(cell)
+	}
+	if len(out.Children) == 0 {
+		out.AppendChild(NewHTMLTag(""))
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import "testing"
+
+var tableTests = map[string]string{
+	"[table][tr][td]a[/td][td]b[/td][/tr][/table]":                                            `<table><tr><td>a</td><td>b</td></tr></table>`,
+	"[table]\n[tr]\n  [th]a[/th]\n  [td]b\nc[/td]\n[/tr]\n[/table]":                           `<table><tr><th>a</th><td>b<br>c</td></tr></table>`,
+	"[table header][tr][td]h[/td][/tr][tr][td][b]x[/b][/td][/tr][/table]":                     `<table><thead><tr><th>h</th></tr></thead><tbody><tr><td><b>x</b></td></tr></tbody></table>`,
+	"[table][tr][td colspan=2 rowspan=1000]a[/td][td colspan=x rowspan=-1][/td][/tr][/table]": `<table><tr><td colspan="2" rowspan="100">a</td><td></td></tr></table>`,
+	"[table]stray[tr]row[td]a[/td][b]x[/b][/tr][/table]":                                      `strayrow<b>x</b><table><tr><td>a</td></tr></table>`,
+	"[table][tr][td]a[/td] x [/tr]junk[/table]":                                               ` x junk<table><tr><td>a</td></tr></table>`,
+	"[table header]1[tr][td]h[/td]2[/tr]3[tr]4[td]b[/td][/tr]5[/table]":                       `12345<table><thead><tr><th>h</th></tr></thead><tbody><tr><td>b</td></tr></tbody></table>`,
+	"[table][/table]":           `<table></table>`,
+	"[table][tr][/tr][/table]":  `<table><tr></tr></table>`,
+	"[tr][td]a[/td][/tr]":       `[tr][td]a[/td][/tr]`,
+	"[table][td]a[/td][/table]": `[td]a[/td]<table></table>`,
+	"[table][tr][td][table][tr][td]in[/td][/tr][/table][/td][/tr][/table]": `<table><tr><td><table><tr><td>in</td></tr></table></td></tr></table>`,
+	"[table][tr][td]a<script>[/td][/tr][/table]":                           `<table><tr><td>a&lt;script&gt;</td></tr></table>`,
+}
+
+func TestTables(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.SortOutputAttributes = true
+	for in, out := range tableTests {
+		result := c.Compile(in)
+		if result != out {
+			t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+		}
+	}
+}
+
+func TestTableParagraphs(t *testing.T) {
+	c := NewCompiler(false, false)
+	c.Paragraphs = true
+	in := "a\n[table]\n[tr][td]b[/td][/tr]\n[/table]\nc"
+	out := `<p>a</p><table><tr><td>b</td></tr></table><p>c</p>`
+	if result := c.Compile(in); result != out {
+		t.Errorf("Failed to compile %s.\nExpected: %s, got: %s\n", in, out, result)
+	}
+}
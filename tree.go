@@ -0,0 +1,156 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children of
+// node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node *BBCodeNode) (w Visitor)
+}
+
+// Walk traverses a tree in depth-first order. The children of a node are
+// collected before they are visited, so the visitor may modify the node it
+// was given, including removing or replacing it.
+func Walk(v Visitor, node *BBCodeNode) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	children := append([]*BBCodeNode(nil), node.Children...)
+	for _, child := range children {
+		Walk(v, child)
+	}
+	v.Visit(nil)
+}
+
+type inspector func(*BBCodeNode) bool
+
+func (f inspector) Visit(node *BBCodeNode) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a tree in depth-first order, calling f(node) for each
+// node. If f returns true, Inspect visits the children of node, followed by
+// a call of f(nil).
+func Inspect(node *BBCodeNode, f func(*BBCodeNode) bool) {
+	Walk(inspector(f), node)
+}
+
+// NewTextNode returns a detached TEXT node.
+func NewTextNode(text string) *BBCodeNode {
+	return &BBCodeNode{Token: Token{ID: TEXT, Value: text}, Children: make([]*BBCodeNode, 0, 5)}
+}
+
+// NewTagNode returns a detached, closed tag node. Its opening and closing
+// tags get generated Raw source, and positions of zero.
+func NewTagNode(name, value string, args map[string]string) *BBCodeNode {
+	if args == nil {
+		args = make(map[string]string)
+	}
+	tag := BBOpeningTag{Name: name, Value: value, Args: args}
+	tag.Raw = "[" + tag.String() + "]"
+	return &BBCodeNode{
+		Token:      Token{ID: OPENING_TAG, Value: tag},
+		Children:   make([]*BBCodeNode, 0, 5),
+		ClosingTag: &BBClosingTag{Name: name, Raw: "[/" + name + "]"},
+	}
+}
+
+// index returns the position of child in the children of n, or -1.
+func (n *BBCodeNode) index(child *BBCodeNode) int {
+	for i, c := range n.Children {
+		if c == child {
+			return i
+		}
+	}
+	return -1
+}
+
+// AppendChild adds child as the last child of n, removing it from its
+// previous parent first.
+func (n *BBCodeNode) AppendChild(child *BBCodeNode) {
+	n.InsertBefore(child, nil)
+}
+
+// contains reports whether node is n or one of its descendants.
+func (n *BBCodeNode) contains(node *BBCodeNode) bool {
+	for ; node != nil; node = node.Parent {
+		if node == n {
+			return true
+		}
+	}
+	return false
+}
+
+// InsertBefore adds child to n just before ref, removing it from its
+// previous parent first. If ref is nil or not a child of n, child is added
+// as the last child. Inserting n, or one of its ancestors, into n would
+// make a cycle, so it does nothing, as does inserting child before itself.
+func (n *BBCodeNode) InsertBefore(child, ref *BBCodeNode) {
+	if child.contains(n) || child == ref {
+		return
+	}
+	child.Remove()
+	i := len(n.Children)
+	if ref != nil {
+		if j := n.index(ref); j >= 0 {
+			i = j
+		}
+	}
+	n.Children = append(n.Children, nil)
+	copy(n.Children[i+1:], n.Children[i:])
+	n.Children[i] = child
+	child.Parent = n
+}
+
+// Remove detaches n from its parent. Its children stay attached to it.
+func (n *BBCodeNode) Remove() {
+	if n.Parent == nil {
+		return
+	}
+	if i := n.Parent.index(n); i >= 0 {
+		n.Parent.Children = append(n.Parent.Children[:i], n.Parent.Children[i+1:]...)
+	}
+	n.Parent = nil
+}
+
+// Replace puts other in the place of n, and detaches n. It does nothing if
+// other is n or one of its ancestors.
+func (n *BBCodeNode) Replace(other *BBCodeNode) {
+	if n.Parent == nil || other.contains(n) {
+		return
+	}
+	n.Parent.InsertBefore(other, n)
+	n.Remove()
+}
+
+// Unwrap replaces n with its children, dropping the tag along with its
+// closing tag.
+func (n *BBCodeNode) Unwrap() {
+	if n.Parent == nil {
+		return
+	}
+	children := append([]*BBCodeNode(nil), n.Children...)
+	for _, child := range children {
+		n.Parent.InsertBefore(child, n)
+	}
+	n.Remove()
+}
+
+// Wrap puts tag in the place of n and moves n inside it, as its last child.
+// A tag without a closing tag is given one, so the wrapper encloses n.
+func (n *BBCodeNode) Wrap(tag *BBCodeNode) {
+	if tag.ID == OPENING_TAG && tag.ClosingTag == nil {
+		name := tag.Value.(BBOpeningTag).Name
+		tag.ClosingTag = &BBClosingTag{Name: name, Raw: "[/" + name + "]"}
+	}
+	if n.Parent != nil {
+		n.Replace(tag)
+	}
+	tag.AppendChild(n)
+}
@@ -0,0 +1,110 @@
+// Copyright 2015 Frustra. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package bbcode
+
+import (
+	"strings"
+	"testing"
+)
+
+func checkParents(t *testing.T, node *BBCodeNode) {
+	for _, child := range node.Children {
+		if child.Parent != node {
+			t.Errorf("Bad parent for %v, expected %v, got %v\n", child.Value, node.Value, child.Parent)
+		}
+		checkParents(t, child)
+	}
+}
+
+func findTag(root *BBCodeNode, name string) *BBCodeNode {
+	var found *BBCodeNode
+	Inspect(root, func(node *BBCodeNode) bool {
+		if found == nil && node != nil && node.ID == OPENING_TAG && node.Value.(BBOpeningTag).Name == name {
+			found = node
+		}
+		return found == nil
+	})
+	return found
+}
+
+func TestInspect(t *testing.T) {
+	root := parseString("a[b]b[i]c[/i][/b][u]d[/u]")
+	var visited []string
+	Inspect(root, func(node *BBCodeNode) bool {
+		if node == nil {
+			visited = append(visited, "nil")
+			return false
+		}
+		if node.ID == OPENING_TAG {
+			visited = append(visited, node.Value.(BBOpeningTag).Name)
+			return node.Value.(BBOpeningTag).Name != "b"
+		}
+		visited = append(visited, node.Value.(string))
+		return true
+	})
+	expected := "a b u d nil nil nil"
+	if result := strings.Join(visited, " "); result != expected {
+		t.Errorf("Failed to inspect tree.\nExpected: %s, got: %s\n", expected, result)
+	}
+}
+
+func TestTreeMutation(t *testing.T) {
+	tests := map[string]func(root *BBCodeNode){
+		`<b>a</b><i>x</i>c`: func(root *BBCodeNode) {
+			findTag(root, "u").Replace(NewTagNode("i", "", nil))
+			findTag(root, "i").AppendChild(NewTextNode("x"))
+		},
+		`<b>a</b>bc`: func(root *BBCodeNode) {
+			findTag(root, "u").Unwrap()
+		},
+		`<b>a</b>c`: func(root *BBCodeNode) {
+			findTag(root, "u").Remove()
+		},
+		`<b>a</b><i><u>b</u></i>c`: func(root *BBCodeNode) {
+			findTag(root, "u").Wrap(NewTagNode("i", "", nil))
+		},
+		`<b>a</b><a href="http://example.com">!</a><u>b</u>c`: func(root *BBCodeNode) {
+			link := NewTagNode("url", "http://example.com", nil)
+			link.AppendChild(NewTextNode("!"))
+			root.InsertBefore(link, findTag(root, "u"))
+		},
+		`<u>b<b>a</b></u>c`: func(root *BBCodeNode) {
+			findTag(root, "u").AppendChild(findTag(root, "b"))
+		},
+		`<b>a</b><u>b</u>c`: func(root *BBCodeNode) {
+			u := findTag(root, "u")
+			u.AppendChild(u)
+			u.Children[0].AppendChild(root)
+			u.InsertBefore(u.Children[0], u.Children[0])
+			root.InsertBefore(u, u)
+			u.Replace(root)
+			u.Children[0].Replace(u)
+		},
+	}
+	for out, mutate := range tests {
+		root := parseString("[b]a[/b][u]b[/u]c")
+		mutate(root)
+		checkParents(t, root)
+		result := NewCompiler(false, false).CompileTree(root).Compile(false)
+		if result != out {
+			t.Errorf("Failed to mutate tree.\nExpected: %s, got: %s\n", out, result)
+		}
+	}
+}
+
+func TestNewTagNode(t *testing.T) {
+	node := NewTagNode("quote", "bob", map[string]string{"post": "1"})
+	if raw := node.Value.(BBOpeningTag).Raw; raw != "[quote=bob post=1]" {
+		t.Errorf("Bad opening raw: %s\n", raw)
+	}
+	if node.ClosingTag == nil || node.ClosingTag.Raw != "[/quote]" {
+		t.Errorf("Bad closing tag: %v\n", node.ClosingTag)
+	}
+	wrapper := &BBCodeNode{Token: Token{ID: OPENING_TAG, Value: BBOpeningTag{Name: "b", Args: map[string]string{}, Raw: "[b]"}}}
+	node.Wrap(wrapper)
+	if wrapper.ClosingTag == nil || wrapper.ClosingTag.Name != "b" || node.Parent != wrapper {
+		t.Errorf("Failed to wrap detached node: %v\n", wrapper)
+	}
+}